@@ -1,22 +1,111 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
+	"io/fs"
 	"log"
 	"net"
 
+	"gemnet/internal/config"
 	"gemnet/internal/server"
 )
 
 func main() {
-	port := ":2323"
-	listener, err := net.Listen("tcp", port)
+	defaults := config.Default()
+
+	configPath := flag.String("config", config.DefaultPath, "path to a TOML config file")
+	listen := flag.String("listen", defaults.Listen, "address to listen on")
+	startURL := flag.String("start-url", defaults.StartURL, "page loaded when a session starts")
+	banner := flag.String("banner", defaults.Banner, "welcome text shown on connect")
+	width := flag.Int("width", defaults.TerminalWidth, "default terminal width")
+	height := flag.Int("height", defaults.TerminalHeight, "default terminal height")
+	setup := defaults.Setup
+	flag.Var(&setup, "setup", "terminal setup screen: auto, always or never")
+	keymapName := flag.String("keymap", defaults.Keymap, "key bindings sessions start with: default, vi, emacs or wordstar")
+	idleTimeout := flag.Duration("idle-timeout", defaults.IdleTimeout, "disconnect after this long without input (0 = never)")
+	maxConns := flag.Int("max-connections", defaults.MaxConnections, "maximum concurrent sessions (0 = unlimited)")
+	knownHosts := flag.String("known-hosts", defaults.KnownHosts, "known hosts file for server certificates (empty = don't check)")
+	strictTOFU := flag.Bool("strict-tofu", defaults.StrictTOFU, "refuse changed server certificates instead of asking")
+	identities := flag.String("identities", defaults.Identities, "directory of client certificate identities (empty = disabled)")
+	maxBodySize := flag.Int64("max-body-size", defaults.MaxBodySize, "truncate responses longer than this many bytes (0 = unlimited)")
+	connectTimeout := flag.Duration("connect-timeout", defaults.ConnectTimeout, "time limit for connecting to a capsule (0 = none)")
+	handshakeTimeout := flag.Duration("handshake-timeout", defaults.HandshakeTimeout, "time limit for the TLS handshake (0 = none)")
+	headerTimeout := flag.Duration("header-timeout", defaults.HeaderTimeout, "time limit for a capsule to start responding (0 = none)")
+	bodyTimeout := flag.Duration("body-timeout", defaults.BodyTimeout, "give up if a page stops arriving for this long (0 = never)")
+	requestTimeout := flag.Duration("request-timeout", defaults.RequestTimeout, "time limit for a whole request (0 = none)")
+	maxConnsPerIP := flag.Int("max-connections-per-ip", defaults.MaxConnectionsPerIP, "maximum concurrent sessions per client address (0 = unlimited)")
+	flag.Parse()
+
+	cfg, err := config.Load(*configPath)
+	if errors.Is(err, fs.ErrNotExist) && *configPath == config.DefaultPath {
+		cfg, err = defaults, nil // No config file; run on the defaults
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Flags given on the command line override the config file
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "listen":
+			cfg.Listen = *listen
+		case "start-url":
+			cfg.StartURL = *startURL
+		case "banner":
+			cfg.Banner = *banner
+		case "width":
+			cfg.TerminalWidth = *width
+		case "height":
+			cfg.TerminalHeight = *height
+		case "setup":
+			cfg.Setup = setup
+		case "keymap":
+			cfg.Keymap = *keymapName
+		case "idle-timeout":
+			cfg.IdleTimeout = *idleTimeout
+		case "max-connections":
+			cfg.MaxConnections = *maxConns
+		case "known-hosts":
+			cfg.KnownHosts = *knownHosts
+		case "strict-tofu":
+			cfg.StrictTOFU = *strictTOFU
+		case "identities":
+			cfg.Identities = *identities
+		case "max-body-size":
+			cfg.MaxBodySize = *maxBodySize
+		case "connect-timeout":
+			cfg.ConnectTimeout = *connectTimeout
+		case "handshake-timeout":
+			cfg.HandshakeTimeout = *handshakeTimeout
+		case "header-timeout":
+			cfg.HeaderTimeout = *headerTimeout
+		case "body-timeout":
+			cfg.BodyTimeout = *bodyTimeout
+		case "request-timeout":
+			cfg.RequestTimeout = *requestTimeout
+		case "max-connections-per-ip":
+			cfg.MaxConnectionsPerIP = *maxConnsPerIP
+		}
+	})
+
+	if err := cfg.Validate(); err != nil {
+		log.Fatal(err)
+	}
+
+	listener, err := net.Listen("tcp", cfg.Listen)
 	if err != nil {
 		log.Fatal(err)
 	}
 	defer listener.Close()
 
-	fmt.Printf("gemnet telnet server listening on %s\n", port)
+	fmt.Printf("gemnet telnet server listening on %s\n", cfg.Listen)
+
+	srv, err := server.New(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	for {
 		conn, err := listener.Accept()
@@ -24,6 +113,6 @@ func main() {
 			log.Println("Error accepting connection:", err)
 			continue
 		}
-		go server.HandleConnection(conn)
+		go srv.HandleConnection(conn)
 	}
 }
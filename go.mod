@@ -1,3 +1,9 @@
 module gemnet
 
 go 1.21
+
+require (
+	github.com/BurntSushi/toml v1.6.0
+	golang.org/x/crypto v0.24.0
+	golang.org/x/text v0.16.0
+)
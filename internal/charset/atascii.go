@@ -0,0 +1,89 @@
+package charset
+
+// ATASCII is the Atari 400/800/XL/XE character set. It follows ASCII
+// from space to 'z', with graphics in place of the control characters.
+// Its own control codes are written as their nearest ASCII equivalents:
+// form feed clears the screen, CR and LF end the line, and backspace
+// moves the cursor left. Inverse video characters have the high bit set
+// and are written as U+E080-U+E0FF (see ATASCIIInverse).
+var ATASCII = &Charset{
+	Name:        "ATASCII",
+	Description: "ATASCII (Atari 8-bit)",
+	aliases:     []string{"atascii", "atari"},
+	encodeRune:  encodeATASCII,
+	fallbacks:   []map[rune]string{atasciiFallbacks},
+}
+
+// ATASCII control codes, and the last graphics character below them
+const (
+	atasciiEOL       = 0x9b
+	atasciiClear     = 0x7d
+	atasciiLeft      = 0x1e
+	atasciiTab       = 0x7f
+	atasciiBell      = 0xfd
+	atasciiLastGraph = 0x1a
+)
+
+// atasciiInverse is the first private use character standing for an
+// inverse video ATASCII byte
+const atasciiInverse = 0xe000
+
+// atasciiGraphics are the graphics characters in the control code range,
+// both light and heavy lines being drawn with the same two-pixel strokes
+var atasciiGraphics = map[rune]byte{
+	'♥': 0x00, '├': 0x01, '┣': 0x01, '▕': 0x02, '┘': 0x03, '┛': 0x03,
+	'┤': 0x04, '┫': 0x04, '┐': 0x05, '┓': 0x05, '╱': 0x06, '╲': 0x07,
+	'◢': 0x08, '▗': 0x09, '◣': 0x0a, '▝': 0x0b, '▘': 0x0c, '▔': 0x0d,
+	'▂': 0x0e, '▖': 0x0f, '♣': 0x10, '┌': 0x11, '┏': 0x11, '─': 0x12,
+	'━': 0x12, '┼': 0x13, '╋': 0x13, '●': 0x14, '▄': 0x15, '▎': 0x16,
+	'┬': 0x17, '┳': 0x17, '┴': 0x18, '┻': 0x18, '▌': 0x19, '└': 0x1a,
+	'┗': 0x1a, '♦': 0x60, '♠': 0x7b, '│': 0x7c, '┃': 0x7c,
+}
+
+func encodeATASCII(r rune) (byte, bool) {
+	switch {
+	case r == '\r', r == '\n':
+		return atasciiEOL, true
+	case r == '\f':
+		return atasciiClear, true
+	case r == '\b':
+		return atasciiLeft, true
+	case r == '\t':
+		return atasciiTab, true
+	case r == '\a':
+		return atasciiBell, true
+	case r >= 0x1b && r <= 0x1f, r >= 0x9b && r <= 0x9f:
+		return byte(r), true // ESC, cursor movement and editing codes
+	case r >= 0x20 && r <= 0x5f, r >= 'a' && r <= 'z', r == '|':
+		return byte(r), true
+	case r >= atasciiInverse+0x80 && r <= atasciiInverse+0xff:
+		return byte(r - atasciiInverse), true
+	}
+	b, ok := atasciiGraphics[r]
+	return b, ok
+}
+
+// ATASCIIInverse returns s in inverse video, which ATASCII shows by
+// setting the high bit of each character. Control codes and characters
+// ATASCII lacks are left as they are.
+func ATASCIIInverse(s string) string {
+	out := make([]rune, 0, len(s))
+	for _, r := range s {
+		b, ok := encodeATASCII(r)
+		if ok && (b <= atasciiLastGraph || b >= 0x20 && b < atasciiClear) {
+			r = atasciiInverse + rune(b|0x80)
+		}
+		out = append(out, r)
+	}
+	return string(out)
+}
+
+// atasciiFallbacks covers the ASCII characters ATASCII lacks and draws
+// double and rounded boxes with its lines
+var atasciiFallbacks = map[rune]string{
+	'{': "(", '}': ")", '~': "-", '`': "'",
+	'═': "─", '║': "│",
+	'╔': "┌", '╭': "┌", '╗': "┐", '╮': "┐",
+	'╚': "└", '╰': "└", '╝': "┘", '╯': "┘",
+	'╠': "├", '╣': "┤", '╦': "┬", '╩': "┴", '╬': "┼",
+}
@@ -0,0 +1,164 @@
+package charset
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// Charset is a character set a terminal can display. Text is
+// transliterated into the characters the charset has, then encoded into
+// its bytes on the way out.
+type Charset struct {
+	Name        string // IANA name, offered in telnet CHARSET negotiation
+	Description string
+
+	aliases []string
+
+	// encodeRune returns the byte for a character, or false if the
+	// charset doesn't have it. nil means UTF-8, which has everything.
+	encodeRune func(r rune) (byte, bool)
+
+	// Substitutes for characters the charset lacks, tried in order
+	// before the shared ASCII fallbacks
+	fallbacks []map[rune]string
+}
+
+var ASCII = &Charset{
+	Name:        "US-ASCII",
+	Description: "US-ASCII",
+	aliases:     []string{"ascii", "us", "ansi_x3.4-1968"},
+	encodeRune:  encodeASCII,
+}
+
+var Latin1 = &Charset{
+	Name:        "ISO-8859-1",
+	Description: "ISO-8859-1 (Latin-1)",
+	aliases:     []string{"latin1", "iso8859-1", "iso_8859-1", "l1"},
+	encodeRune:  charmap.ISO8859_1.EncodeRune,
+	fallbacks:   []map[rune]string{latin1Fallbacks},
+}
+
+var CP437 = &Charset{
+	Name:        "IBM437",
+	Description: "CP437 (IBM PC)",
+	aliases:     []string{"cp437", "437", "pc8"},
+	encodeRune:  charmap.CodePage437.EncodeRune,
+	fallbacks:   []map[rune]string{cp437Fallbacks, pcBoxFallbacks},
+}
+
+var CP850 = &Charset{
+	Name:        "IBM850",
+	Description: "CP850 (DOS Western Europe)",
+	aliases:     []string{"cp850", "850"},
+	encodeRune:  charmap.CodePage850.EncodeRune,
+	fallbacks:   []map[rune]string{cp850Fallbacks, pcBoxFallbacks},
+}
+
+var MacRoman = &Charset{
+	Name:        "macintosh",
+	Description: "Mac Roman",
+	aliases:     []string{"macroman", "mac", "x-mac-roman"},
+	encodeRune:  charmap.Macintosh.EncodeRune,
+	fallbacks:   []map[rune]string{macRomanFallbacks},
+}
+
+var UTF8 = &Charset{
+	Name:        "UTF-8",
+	Description: "UTF-8",
+	aliases:     []string{"utf8"},
+}
+
+// Charsets lists every available charset in menu order
+var Charsets = []*Charset{ASCII, Latin1, CP437, CP850, MacRoman, UTF8}
+
+// Lookup returns the charset with the given name or alias, or nil
+func Lookup(name string) *Charset {
+	name = strings.TrimSpace(name)
+	for _, c := range Charsets {
+		if strings.EqualFold(c.Name, name) {
+			return c
+		}
+		for _, alias := range c.aliases {
+			if strings.EqualFold(alias, name) {
+				return c
+			}
+		}
+	}
+	return nil
+}
+
+// Terminal types whose clients are known to use a particular charset,
+// matched by prefix in order
+var terminalTypes = []struct {
+	prefix  string
+	charset *Charset
+}{
+	{"syncterm", CP437},
+	{"pcansi", CP437},
+	{"ansi-bbs", CP437},
+	{"ansi", CP437},
+	{"xterm-256color", UTF8},
+	{"xterm-kitty", UTF8},
+	{"xterm-ghostty", UTF8},
+	{"alacritty", UTF8},
+	{"foot", UTF8},
+	{"wezterm", UTF8},
+	{"tmux", UTF8},
+	{"screen-256color", UTF8},
+	{"rxvt-unicode", UTF8},
+}
+
+// ForTerminalType picks a charset for a TERMINAL-TYPE name reported by
+// the client (RFC 1091). It returns nil for names it doesn't recognise.
+func ForTerminalType(name string) *Charset {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, t := range terminalTypes {
+		if strings.HasPrefix(name, t.prefix) {
+			return t.charset
+		}
+	}
+	return nil
+}
+
+// Has reports whether the charset can show r
+func (c *Charset) Has(r rune) bool {
+	if c.encodeRune == nil {
+		return true
+	}
+	_, ok := c.encodeRune(r)
+	return ok
+}
+
+// hasAll reports whether the charset can show every character of s
+func (c *Charset) hasAll(s string) bool {
+	for _, r := range s {
+		if !c.Has(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// Encode converts UTF-8 output to the charset's bytes. Characters it
+// doesn't have become '?', so text should be transliterated first.
+func (c *Charset) Encode(data []byte) []byte {
+	if c.encodeRune == nil {
+		return data
+	}
+
+	out := make([]byte, 0, len(data))
+	for _, r := range string(data) {
+		b, ok := c.encodeRune(r)
+		if !ok {
+			b, _ = c.encodeRune('?')
+		}
+		out = append(out, b)
+	}
+	return out
+}
+
+func encodeASCII(r rune) (byte, bool) {
+	return byte(r), r < utf8.RuneSelf
+}
@@ -0,0 +1,88 @@
+package charset
+
+// emojiNames gives the :shortname: shown for common emoji. Others show
+// as [emoji].
+var emojiNames = map[rune]string{
+	// Faces
+	'😀': "grinning", '😃': "smiley", '😄': "smile", '😁': "grin", '😆': "laughing",
+	'😅': "sweat_smile", '😂': "joy", '🤣': "rofl", '🙂': "slightly_smiling_face",
+	'🙃': "upside_down_face", '😉': "wink", '😊': "blush", '😇': "innocent",
+	'😍': "heart_eyes", '😘': "kissing_heart", '😋': "yum", '😛': "stuck_out_tongue",
+	'😜': "stuck_out_tongue_winking_eye", '🤪': "zany_face", '🤓': "nerd_face",
+	'😎': "sunglasses", '🤔': "thinking", '🤨': "raised_eyebrow", '😐': "neutral_face",
+	'😑': "expressionless", '😶': "no_mouth", '🙄': "roll_eyes", '😏': "smirk",
+	'😬': "grimacing", '😌': "relieved", '😔': "pensive", '😴': "sleeping",
+	'😷': "mask", '🤒': "face_with_thermometer", '🤯': "exploding_head",
+	'🥳': "partying_face", '😕': "confused", '🙁': "slightly_frowning_face",
+	'☹': "frowning_face", '😮': "open_mouth", '😲': "astonished", '😳': "flushed",
+	'🥺': "pleading_face", '😢': "cry", '😭': "sob", '😱': "scream", '😞': "disappointed",
+	'😩': "weary", '😫': "tired_face", '😤': "triumph", '😡': "rage", '😠': "angry",
+	'🤬': "cursing_face", '💀': "skull", '💩': "poop", '🤡': "clown_face",
+	'👻': "ghost", '👽': "alien", '👾': "space_invader", '🤖': "robot",
+	'😺': "smiley_cat", '🙈': "see_no_evil",
+
+	// Hands and people
+	'👋': "wave", '✋': "raised_hand", '👌': "ok_hand", '✌': "v", '🤞': "crossed_fingers",
+	'👈': "point_left", '👉': "point_right", '👆': "point_up_2", '👇': "point_down",
+	'👍': "+1", '👎': "-1", '✊': "fist", '👊': "punch", '👏': "clap", '🙌': "raised_hands",
+	'🙏': "pray", '💪': "muscle", '👀': "eyes", '🧠': "brain", '👤': "bust_in_silhouette",
+	'🤷': "shrug", '🤦': "facepalm",
+
+	// Hearts and symbols
+	'❤': "heart", '🧡': "orange_heart", '💛': "yellow_heart", '💚': "green_heart",
+	'💙': "blue_heart", '💜': "purple_heart", '🖤': "black_heart", '💔': "broken_heart",
+	'💕': "two_hearts", '💯': "100", '💢': "anger", '💥': "boom", '💫': "dizzy",
+	'💬': "speech_balloon", '💭': "thought_balloon", '💤': "zzz",
+	'✅': "white_check_mark", '❌': "x", '❎': "negative_squared_cross_mark",
+	'⚠': "warning", '⛔': "no_entry", '🚫': "no_entry_sign", '❗': "exclamation",
+	'❓': "question", '❕': "grey_exclamation", '❔': "grey_question", '➕': "heavy_plus_sign",
+	'➖': "heavy_minus_sign", '➡': "arrow_right", '⬅': "arrow_left", '⬆': "arrow_up",
+	'⬇': "arrow_down", '🔴': "red_circle", '🟢': "green_circle", '🔵': "large_blue_circle",
+	'⭐': "star", '🌟': "star2", '✨': "sparkles", '⚡': "zap", '🔥': "fire",
+	'🆗': "ok", '🆕': "new", '🆓': "free", '🔝': "top", '♻': "recycle",
+
+	// Nature and weather
+	'☀': "sunny", '🌞': "sun_with_face", '⛅': "partly_sunny", '☁': "cloud", '🌧': "cloud_with_rain",
+	'⛈': "cloud_with_lightning_and_rain", '❄': "snowflake", '☃': "snowman", '⛄': "snowman",
+	'☔': "umbrella", '🌈': "rainbow", '🌊': "ocean", '🌙': "crescent_moon", '🌍': "earth_africa",
+	'🌎': "earth_americas", '🌏': "earth_asia", '🌱': "seedling", '🌲': "evergreen_tree",
+	'🌳': "deciduous_tree", '🌵': "cactus", '🌷': "tulip", '🌸': "cherry_blossom",
+	'🌹': "rose", '🌻': "sunflower", '🍀': "four_leaf_clover", '🍁': "maple_leaf",
+	'🐱': "cat", '🐶': "dog", '🐭': "mouse", '🐰': "rabbit", '🦊': "fox_face", '🐻': "bear",
+	'🐼': "panda_face", '🐸': "frog", '🐵': "monkey_face", '🐔': "chicken", '🐧': "penguin",
+	'🐦': "bird", '🦆': "duck", '🦉': "owl", '🐝': "bee", '🐛': "bug", '🦋': "butterfly",
+	'🐌': "snail", '🐢': "turtle", '🐍': "snake", '🐙': "octopus", '🦀': "crab",
+	'🐟': "fish", '🐬': "dolphin", '🐳': "whale", '🦄': "unicorn", '🐉': "dragon",
+
+	// Food and drink
+	'🍎': "apple", '🍌': "banana", '🍓': "strawberry", '🍋': "lemon", '🍅': "tomato",
+	'🥑': "avocado", '🍞': "bread", '🧀': "cheese", '🍕': "pizza", '🍔': "hamburger",
+	'🍟': "fries", '🌮': "taco", '🍜': "ramen", '🍣': "sushi", '🍰': "cake",
+	'🎂': "birthday", '🍪': "cookie", '🍫': "chocolate_bar", '🍩': "doughnut",
+	'☕': "coffee", '🍵': "tea", '🍺': "beer", '🍻': "beers", '🍷': "wine_glass", '🥂': "clinking_glasses",
+
+	// Objects and activities
+	'🎉': "tada", '🎊': "confetti_ball", '🎁': "gift", '🎈': "balloon", '🎄': "christmas_tree",
+	'🎃': "jack_o_lantern", '🏆': "trophy", '⚽': "soccer", '🎮': "video_game",
+	'🕹': "joystick", '🎲': "game_die", '♟': "chess_pawn", '🎵': "musical_note", '🎶': "notes",
+	'🎸': "guitar", '🎨': "art", '🎬': "clapper", '📷': "camera", '📺': "tv", '📻': "radio",
+	'💻': "computer", '🖥': "desktop_computer", '⌨': "keyboard", '🖱': "computer_mouse",
+	'💾': "floppy_disk", '💿': "cd", '📀': "dvd", '📱': "iphone", '☎': "phone", '📞': "telephone_receiver",
+	'📡': "satellite", '🔋': "battery", '🔌': "electric_plug", '💡': "bulb", '🔦': "flashlight",
+	'📚': "books", '📖': "book", '📝': "memo", '✏': "pencil2", '✒': "black_nib", '📌': "pushpin",
+	'📎': "paperclip", '✂': "scissors", '📁': "file_folder", '📂': "open_file_folder",
+	'📄': "page_facing_up", '📅': "date", '📆': "calendar", '📈': "chart_with_upwards_trend",
+	'📉': "chart_with_downwards_trend", '📊': "bar_chart", '📋': "clipboard",
+	'📦': "package", '📧': "e-mail", '✉': "envelope", '📨': "incoming_envelope", '📮': "postbox",
+	'🔒': "lock", '🔓': "unlock", '🔑': "key", '🔨': "hammer", '🔧': "wrench", '⚙': "gear",
+	'🔗': "link", '🔍': "mag", '🔎': "mag_right", '🔔': "bell", '🔕': "no_bell",
+	'📣': "mega", '📢': "loudspeaker", '⏰': "alarm_clock", '⌛': "hourglass", '⏳': "hourglass_flowing_sand",
+	'⌚': "watch", '💰': "moneybag", '💸': "money_with_wings", '💎': "gem", '🧪': "test_tube",
+	'🔬': "microscope", '🔭': "telescope", '🧭': "compass", '🗺': "world_map",
+
+	// Travel and places
+	'🚀': "rocket", '✈': "airplane", '🚗': "car", '🚲': "bike", '🚂': "steam_locomotive",
+	'🚢': "ship", '⛵': "boat", '🏠': "house", '🏡': "house_with_garden", '🏢': "office",
+	'🏰': "european_castle", '⛺': "tent", '🗻': "mount_fuji", '🏖': "beach_umbrella",
+	'🚧': "construction", '🚨': "rotating_light", '🏁': "checkered_flag", '🚩': "triangular_flag_on_post",
+}
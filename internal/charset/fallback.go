@@ -0,0 +1,146 @@
+package charset
+
+// asciiFallbacks are 7-bit substitutes used by every charset when it has
+// nothing closer of its own. Accented letters aren't listed; they lose
+// their accents through decomposition instead.
+var asciiFallbacks = merge(symbolFallbacks, latinLetters, greekLetters, cyrillicLetters, boxDrawing())
+
+var symbolFallbacks = map[rune]string{
+	// Quotes
+	'‘': "'", '’': "'", '‚': ",", '“': "\"", '”': "\"", '„': "\"",
+	'´': "'", '′': "'", '″': "\"",
+	'«': "<<", '»': ">>", '‹': "<", '›': ">",
+	// Dashes
+	'—': "-", '–': "-", '−': "-", '‐': "-", '‑': "-",
+	// Other common symbols
+	'•': "*", '·': ".", '∙': ".", '…': "...",
+	'©': "(c)", '®': "(R)", '™': "(TM)",
+	'°': " deg", '±': "+/-",
+	'×': "x", '÷': "/", '⁄': "/",
+	'€': "EUR", '£': "GBP", '¥': "YEN", '¢': "c",
+	'¡': "!", '¿': "?", '§': "S", '¶': "P", '¦': "|",
+	'≤': "<=", '≥': ">=", '≠': "!=", '≈': "~",
+	'←': "<-", '→': "->", '↑': "^", '↓': "v", '⇐': "<=", '⇒': "=>",
+	'√': "v", '✓': "v", '✔': "v", '✗': "x", '✘': "x",
+	'★': "*", '☆': "*", '♥': "<3", '♪': "~", '♫': "~",
+	'☐': "[ ]", '☑': "[x]", '☒': "[x]",
+	// Block elements
+	'█': "#", '▓': "#", '▒': ":", '░': ".",
+	'▀': "#", '▄': "#", '▌': "#", '▐': "#", '■': "#", '▪': "#",
+}
+
+// Letters with no decomposition into a base letter and accents
+var latinLetters = map[rune]string{
+	'æ': "ae", 'Æ': "AE", 'œ': "oe", 'Œ': "OE", 'ß': "ss", 'ẞ': "SS",
+	'ø': "o", 'Ø': "O", 'ł': "l", 'Ł': "L", 'đ': "d", 'Đ': "D",
+	'ð': "d", 'Ð': "D", 'þ': "th", 'Þ': "Th", 'ħ': "h", 'Ħ': "H",
+	'ı': "i", 'ĸ': "q", 'ŋ': "ng", 'Ŋ': "Ng", 'ſ': "s", 'ŧ': "t", 'Ŧ': "T",
+	'ƒ': "f", 'ə': "e", 'Ə': "E",
+}
+
+// greekLetters romanizes Greek, roughly following ELOT 743. Accented
+// letters decompose to these.
+var greekLetters = map[rune]string{
+	'Α': "A", 'Β': "V", 'Γ': "G", 'Δ': "D", 'Ε': "E", 'Ζ': "Z", 'Η': "I", 'Θ': "Th",
+	'Ι': "I", 'Κ': "K", 'Λ': "L", 'Μ': "M", 'Ν': "N", 'Ξ': "X", 'Ο': "O", 'Π': "P",
+	'Ρ': "R", 'Σ': "S", 'Τ': "T", 'Υ': "Y", 'Φ': "F", 'Χ': "Ch", 'Ψ': "Ps", 'Ω': "O",
+	'α': "a", 'β': "v", 'γ': "g", 'δ': "d", 'ε': "e", 'ζ': "z", 'η': "i", 'θ': "th",
+	'ι': "i", 'κ': "k", 'λ': "l", 'μ': "m", 'ν': "n", 'ξ': "x", 'ο': "o", 'π': "p",
+	'ρ': "r", 'σ': "s", 'ς': "s", 'τ': "t", 'υ': "y", 'φ': "f", 'χ': "ch", 'ψ': "ps",
+	'ω': "o",
+}
+
+// cyrillicLetters romanizes Russian, Ukrainian, Belarusian, Bulgarian,
+// Serbian and Macedonian
+var cyrillicLetters = map[rune]string{
+	'А': "A", 'Б': "B", 'В': "V", 'Г': "G", 'Д': "D", 'Е': "E", 'Ё': "Yo", 'Ж': "Zh",
+	'З': "Z", 'И': "I", 'Й': "Y", 'К': "K", 'Л': "L", 'М': "M", 'Н': "N", 'О': "O",
+	'П': "P", 'Р': "R", 'С': "S", 'Т': "T", 'У': "U", 'Ф': "F", 'Х': "Kh", 'Ц': "Ts",
+	'Ч': "Ch", 'Ш': "Sh", 'Щ': "Shch", 'Ъ': "\"", 'Ы': "Y", 'Ь': "'", 'Э': "E", 'Ю': "Yu",
+	'Я': "Ya",
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "yo", 'ж': "zh",
+	'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o",
+	'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts",
+	'ч': "ch", 'ш': "sh", 'щ': "shch", 'ъ': "\"", 'ы': "y", 'ь': "'", 'э': "e", 'ю': "yu",
+	'я': "ya",
+	// Ukrainian and Belarusian
+	'Є': "Ye", 'є': "ye", 'І': "I", 'і': "i", 'Ї': "Yi", 'ї': "yi", 'Ґ': "G", 'ґ': "g",
+	'Ў': "U", 'ў': "u",
+	// Serbian and Macedonian
+	'Ђ': "Dj", 'ђ': "dj", 'Ј': "J", 'ј': "j", 'Љ': "Lj", 'љ': "lj", 'Њ': "Nj", 'њ': "nj",
+	'Ћ': "C", 'ћ': "c", 'Џ': "Dz", 'џ': "dz", 'Ѓ': "Gj", 'ѓ': "gj", 'Ѕ': "Dz", 'ѕ': "dz",
+	'Ќ': "Kj", 'ќ': "kj",
+}
+
+// merge combines fallback tables into one
+func merge(tables ...map[rune]string) map[rune]string {
+	merged := make(map[rune]string)
+	for _, table := range tables {
+		for r, sub := range table {
+			merged[r] = sub
+		}
+	}
+	return merged
+}
+
+// boxDrawing returns ASCII line art for the Box Drawing block
+// (U+2500-U+257F)
+func boxDrawing() map[rune]string {
+	table := make(map[rune]string)
+	horizontal := "─━┄┅┈┉╌╍╴╶╸╺╼╾"
+	vertical := "│┃┆┇┊┋╎╏╵╷╹╻╽╿"
+	for r := rune(0x2500); r <= 0x257f; r++ {
+		table[r] = "+"
+	}
+	for _, r := range horizontal {
+		table[r] = "-"
+	}
+	for _, r := range vertical {
+		table[r] = "|"
+	}
+	table['═'] = "="
+	table['║'] = "|"
+	table['╱'] = "/"
+	table['╲'] = "\\"
+	table['╳'] = "X"
+	return table
+}
+
+// pcBoxFallbacks draws box characters missing from the DOS code pages
+// with the nearest ones they have: heavy lines as double, rounded
+// corners as square, dashed lines as solid, and (for CP850, which only
+// has pure single and double pieces) mixed pieces as double
+var pcBoxFallbacks = map[rune]string{
+	'━': "═", '┃': "║", '┏': "╔", '┓': "╗", '┗': "╚", '┛': "╝",
+	'┣': "╠", '┫': "╣", '┳': "╦", '┻': "╩", '╋': "╬",
+	'╭': "┌", '╮': "┐", '╯': "┘", '╰': "└",
+	'┄': "─", '┅': "═", '┈': "─", '┉': "═", '╌': "─", '╍': "═",
+	'┆': "│", '┇': "║", '┊': "│", '┋': "║", '╎': "│", '╏': "║",
+	'╒': "╔", '╓': "╔", '╕': "╗", '╖': "╗", '╘': "╚", '╙': "╚", '╛': "╝", '╜': "╝",
+	'╞': "╠", '╟': "╠", '╡': "╣", '╢': "╣", '╤': "╦", '╥': "╦", '╧': "╩", '╨': "╩",
+	'╪': "╬", '╫': "╬",
+	'▌': "█", '▐': "█",
+}
+
+var latin1Fallbacks = map[rune]string{
+	'•': "·", '∙': "·", '⋅': "·", '‣': "·",
+	'μ': "µ",
+}
+
+var cp437Fallbacks = map[rune]string{
+	'•': "∙", '⋅': "·", '‣': "∙",
+	'β': "ß", 'μ': "µ", '∑': "Σ", 'ϕ': "φ",
+	'✓': "√",
+	'▪': "■", '◼': "■",
+}
+
+var cp850Fallbacks = map[rune]string{
+	'•': "·", '∙': "·", '⋅': "·", '‣': "·",
+	'μ': "µ",
+	'▪': "■", '◼': "■",
+}
+
+var macRomanFallbacks = map[rune]string{
+	'∙': "•", '⋅': "·", '‣': "•",
+	'Σ': "∑", 'Π': "∏", 'μ': "µ", '✓': "√",
+}
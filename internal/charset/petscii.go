@@ -0,0 +1,55 @@
+package charset
+
+// PETSCII is the Commodore 64/128 character set in its lower/upper case
+// mode, which terminals switch to with control code 0x0E. Lower case
+// letters sit where ASCII has upper case, and upper case letters at
+// 0xC1-0xDA. Control codes in 0x80-0x9F (reverse off, colours, cursor
+// up and left) are written as the C1 control characters U+0080-U+009F.
+var PETSCII = &Charset{
+	Name:        "PETSCII",
+	Description: "PETSCII (Commodore)",
+	aliases:     []string{"petscii", "commodore", "c64"},
+	encodeRune:  encodePETSCII,
+	fallbacks:   []map[rune]string{petsciiFallbacks},
+}
+
+// petsciiGraphics are the line and block graphics shared by both
+// PETSCII character modes
+var petsciiGraphics = map[rune]byte{
+	'£': 0x5c, '↑': 0x5e, '←': 0x5f,
+	'─': 0xc0, '┼': 0xdb, '│': 0xdd, '|': 0xdd,
+	'\u00a0': 0xa0, '▌': 0xa1, '▄': 0xa2, '▔': 0xa3, '▁': 0xa4, '▏': 0xa5, '▒': 0xa6, '▕': 0xa7,
+	'├': 0xab, '▗': 0xac, '└': 0xad, '┐': 0xae, '▂': 0xaf,
+	'┌': 0xb0, '┴': 0xb1, '┬': 0xb2, '┤': 0xb3, '▎': 0xb4, '▍': 0xb5,
+	'▃': 0xb9, '▖': 0xbb, '▝': 0xbc, '┘': 0xbd, '▘': 0xbe, '▚': 0xbf,
+}
+
+func encodePETSCII(r rune) (byte, bool) {
+	switch {
+	case r == '\b':
+		return 0x9d, true // Cursor left
+	case r == '\n':
+		return 0x0d, true
+	case r < 0x20, r >= 0x80 && r <= 0x9f:
+		return byte(r), true
+	case r >= 'a' && r <= 'z':
+		return byte(r - 'a' + 0x41), true
+	case r >= 'A' && r <= 'Z':
+		return byte(r - 'A' + 0xc1), true
+	case r >= 0x20 && r <= 0x40, r == '[', r == ']':
+		return byte(r), true
+	}
+	b, ok := petsciiGraphics[r]
+	return b, ok
+}
+
+// petsciiFallbacks covers the ASCII characters PETSCII lacks and draws
+// double and heavy boxes with its single lines
+var petsciiFallbacks = map[rune]string{
+	'\\': "/", '_': "-", '{': "(", '}': ")", '~': "-", '`': "'", '^': "↑",
+	'═': "─", '━': "─", '║': "│", '┃': "│",
+	'╔': "┌", '┏': "┌", '╭': "┌", '╗': "┐", '┓': "┐", '╮': "┐",
+	'╚': "└", '┗': "└", '╰': "└", '╝': "┘", '┛': "┘", '╯': "┘",
+	'╠': "├", '┣': "├", '╣': "┤", '┫': "┤", '╦': "┬", '┳': "┬", '╩': "┴", '┻': "┴",
+	'╬': "┼", '╋': "┼",
+}
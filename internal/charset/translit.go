@@ -0,0 +1,204 @@
+package charset
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Characters that combine with their neighbours into a single emoji
+const (
+	zeroWidthJoiner = '\u200d'
+	regionalA       = 0x1f1e6 // Regional indicator A; pairs of these are flags
+	regionalZ       = 0x1f1ff
+)
+
+// cjkPlaceholder stands in for a run of Chinese, Japanese or Korean text
+const cjkPlaceholder = "[CJK]"
+
+// tabWidth is the distance between the tab stops tabs are expanded to
+const tabWidth = 8
+
+// Transliterate replaces the characters of s that the charset can't
+// show with the closest substitutes it has. Control characters are
+// removed, so page text can't clear the screen or move the cursor, and
+// tabs become spaces. The result is still UTF-8.
+func (c *Charset) Transliterate(s string) string {
+	s = stripControls(s)
+	if c.encodeRune == nil {
+		return s
+	}
+
+	// Compose first, so a letter followed by a combining accent is
+	// treated like the same accented letter typed as one character
+	runes := []rune(norm.NFC.String(s))
+
+	var result strings.Builder
+	result.Grow(len(s))
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case c.Has(r) && !(isGreek(r) && inGreekWord(runes, i)):
+			result.WriteRune(r)
+
+		case isCJK(r):
+			// Collapse the whole run, including spaces between words
+			for i+1 < len(runes) && (isCJK(runes[i+1]) || runes[i+1] == ' ' && i+2 < len(runes) && isCJK(runes[i+2])) {
+				i++
+			}
+			result.WriteString(cjkPlaceholder)
+
+		case r >= regionalA && r <= regionalZ && i+1 < len(runes) && runes[i+1] >= regionalA && runes[i+1] <= regionalZ:
+			// A flag shows as its country code
+			result.WriteString("[" + string('A'+r-regionalA) + string('A'+runes[i+1]-regionalA) + "]")
+			i++
+
+		case isGreek(r):
+			result.WriteString(c.romanize(r))
+
+		default:
+			result.WriteString(c.substitute(r))
+		}
+
+		// Only the first emoji of a joined sequence (a family, a
+		// profession) is shown
+		for i+2 < len(runes) && runes[i+1] == zeroWidthJoiner {
+			i += 2
+		}
+	}
+	return result.String()
+}
+
+// substitute finds a replacement for a character the charset lacks
+func (c *Charset) substitute(r rune) string {
+	for _, table := range c.fallbacks {
+		if sub, ok := table[r]; ok && c.hasAll(sub) {
+			return sub
+		}
+	}
+	return c.romanize(r)
+}
+
+// romanize finds a replacement for r without the charset's own
+// substitutes, so Greek words come out in Latin letters even if the
+// charset has a few Greek symbols
+func (c *Charset) romanize(r rune) string {
+	if sub, ok := asciiFallbacks[r]; ok {
+		return sub
+	}
+	if sub, ok := c.decompose(r); ok {
+		return sub
+	}
+	if isEmoji(r) {
+		if name, ok := emojiNames[r]; ok {
+			return ":" + name + ":"
+		}
+		return "[emoji]"
+	}
+	if unicode.IsSpace(r) {
+		return " "
+	}
+	if unicode.Is(unicode.Mn, r) || isEmojiModifier(r) {
+		// Accents the charset can't combine, variation selectors and
+		// skin tones are dropped
+		return ""
+	}
+	return "?"
+}
+
+// decompose splits r into a base letter and accents (or its compatibility
+// equivalent, such as "fi" for the ligature or "2" for superscript two),
+// keeping as much as the charset can show
+func (c *Charset) decompose(r rune) (string, bool) {
+	decomposed := []rune(norm.NFKD.String(string(r)))
+	if len(decomposed) == 1 && decomposed[0] == r {
+		return "", false
+	}
+
+	// Keep the first accent if the charset has that combination, so
+	// Vietnamese ệ becomes ê rather than e
+	if len(decomposed) > 2 && unicode.Is(unicode.Mn, decomposed[1]) {
+		partial := []rune(norm.NFC.String(string(decomposed[:2])))
+		if len(partial) == 1 && c.Has(partial[0]) && !isGreek(partial[0]) {
+			return string(partial), true
+		}
+	}
+
+	var result strings.Builder
+	for _, d := range decomposed {
+		switch {
+		case unicode.Is(unicode.Mn, d):
+			// Drop accents
+		case c.Has(d) && !isGreek(d):
+			result.WriteRune(d)
+		default:
+			result.WriteString(c.romanize(d))
+		}
+	}
+	return result.String(), true
+}
+
+// inGreekWord reports whether the Greek letter at i has another Greek
+// letter next to it. Lone letters like π are kept where the charset has
+// them; whole words are romanized rather than mixing alphabets.
+func inGreekWord(runes []rune, i int) bool {
+	return i > 0 && isGreek(runes[i-1]) || i+1 < len(runes) && isGreek(runes[i+1])
+}
+
+func isGreek(r rune) bool {
+	return unicode.Is(unicode.Greek, r) && unicode.IsLetter(r)
+}
+
+// isCJK reports whether r is a Chinese, Japanese or Korean character or
+// punctuation mark
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) ||
+		r >= 0x3000 && r <= 0x303f // CJK Symbols and Punctuation
+}
+
+// isEmoji reports whether r is in one of the pictograph blocks
+func isEmoji(r rune) bool {
+	return r >= 0x1f000 && r <= 0x1faff && !isEmojiModifier(r) ||
+		r >= 0x2600 && r <= 0x27bf || // Miscellaneous Symbols, Dingbats
+		r >= 0x2b00 && r <= 0x2bff // Miscellaneous Symbols and Arrows
+}
+
+// isEmojiModifier reports whether r only changes how the previous emoji
+// looks
+func isEmojiModifier(r rune) bool {
+	return r >= 0x1f3fb && r <= 0x1f3ff || // Skin tones
+		r >= 0xfe00 && r <= 0xfe0f // Variation selectors
+}
+
+// stripControls removes control characters from s, expanding tabs to the
+// next tab stop
+func stripControls(s string) string {
+	if strings.IndexFunc(s, isControl) < 0 {
+		return s
+	}
+
+	var result strings.Builder
+	result.Grow(len(s))
+	column := 0
+	for _, r := range s {
+		switch {
+		case r == '\t':
+			spaces := tabWidth - column%tabWidth
+			result.WriteString(strings.Repeat(" ", spaces))
+			column += spaces
+		case isControl(r):
+			// Dropped
+		default:
+			result.WriteRune(r)
+			column++
+		}
+	}
+	return result.String()
+}
+
+// isControl reports whether r is a C0 or C1 control character, DEL, or
+// one of the private use characters that stand for ATASCII inverse video
+func isControl(r rune) bool {
+	return unicode.IsControl(r) || r >= atasciiInverse+0x80 && r <= atasciiInverse+0xff
+}
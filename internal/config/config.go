@@ -0,0 +1,157 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+
+	"gemnet/internal/keymap"
+)
+
+// Config holds the server settings, read from a TOML file and command-line flags
+type Config struct {
+	Listen   string `toml:"listen"`    // Address to listen on, e.g. ":2323"
+	StartURL string `toml:"start_url"` // Page loaded when a session starts
+	Banner   string `toml:"banner"`    // Welcome text shown on connect
+
+	// Terminal size used until the client reports its own
+	TerminalWidth  int `toml:"terminal_width"`
+	TerminalHeight int `toml:"terminal_height"`
+
+	Setup  SetupMode `toml:"setup"`  // When to show the terminal setup screen
+	Keymap string    `toml:"keymap"` // Key bindings sessions start with: default, vi, emacs or wordstar
+
+	// Trust-On-First-Use store for server certificates ("" disables checks)
+	KnownHosts string `toml:"known_hosts"`
+	StrictTOFU bool   `toml:"strict_tofu"` // Refuse changed certificates instead of asking
+
+	// Directory of client certificate identities ("" disables them)
+	Identities string `toml:"identities"`
+
+	MaxBodySize int64 `toml:"max_body_size"` // Responses longer than this many bytes are truncated (0 = unlimited)
+
+	// Limits on how long a Gemini request may take (0 = no limit)
+	ConnectTimeout   time.Duration `toml:"connect_timeout"`   // Establishing the TCP connection
+	HandshakeTimeout time.Duration `toml:"handshake_timeout"` // Completing the TLS handshake
+	HeaderTimeout    time.Duration `toml:"header_timeout"`    // Waiting for the response header
+	BodyTimeout      time.Duration `toml:"body_timeout"`      // Longest gap while the body arrives
+	RequestTimeout   time.Duration `toml:"request_timeout"`   // The whole request
+
+	IdleTimeout         time.Duration `toml:"idle_timeout"`           // Disconnect after this long without input (0 = never)
+	MaxConnections      int           `toml:"max_connections"`        // Total concurrent sessions (0 = unlimited)
+	MaxConnectionsPerIP int           `toml:"max_connections_per_ip"` // Concurrent sessions per client address (0 = unlimited)
+}
+
+// Default returns the built-in configuration
+func Default() *Config {
+	return &Config{
+		Listen:           ":2323",
+		StartURL:         "gemini://geminiprotocol.net/",
+		Banner:           "Welcome to gemnet - Gemini over Telnet",
+		TerminalWidth:    80,
+		TerminalHeight:   24,
+		Setup:            SetupAuto,
+		Keymap:           "default",
+		KnownHosts:       "known_hosts",
+		Identities:       "identities",
+		MaxBodySize:      4 * 1024 * 1024,
+		ConnectTimeout:   10 * time.Second,
+		HandshakeTimeout: 10 * time.Second,
+		HeaderTimeout:    30 * time.Second,
+		BodyTimeout:      30 * time.Second,
+		RequestTimeout:   5 * time.Minute,
+		IdleTimeout:      30 * time.Minute,
+	}
+}
+
+// DefaultPath is the config file read when none is given on the command
+// line, relative to the working directory. Unlike a file that is asked
+// for, it doesn't have to exist.
+const DefaultPath = "gemnet.toml"
+
+// Load reads a TOML config file on top of the defaults
+func Load(path string) (*Config, error) {
+	cfg := Default()
+	md, err := toml.DecodeFile(path, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		return nil, fmt.Errorf("%s: unknown setting %q", path, undecoded[0].String())
+	}
+	return cfg, nil
+}
+
+// Validate checks that settings are in range
+func (c *Config) Validate() error {
+	if c.Listen == "" {
+		return fmt.Errorf("listen address must not be empty")
+	}
+	if !strings.HasPrefix(c.StartURL, "gemini://") {
+		return fmt.Errorf("start_url must be a gemini:// URL")
+	}
+	if c.TerminalWidth < 20 || c.TerminalWidth > 255 {
+		return fmt.Errorf("terminal_width must be between 20 and 255")
+	}
+	if c.TerminalHeight < 4 || c.TerminalHeight > 255 {
+		return fmt.Errorf("terminal_height must be between 4 and 255")
+	}
+	if keymap.Lookup(c.Keymap) == nil {
+		return fmt.Errorf("keymap must be default, vi, emacs or wordstar")
+	}
+	if c.MaxBodySize < 0 {
+		return fmt.Errorf("max_body_size must not be negative")
+	}
+	if c.ConnectTimeout < 0 || c.HandshakeTimeout < 0 || c.HeaderTimeout < 0 ||
+		c.BodyTimeout < 0 || c.RequestTimeout < 0 {
+		return fmt.Errorf("request timeouts must not be negative")
+	}
+	if c.IdleTimeout < 0 {
+		return fmt.Errorf("idle_timeout must not be negative")
+	}
+	if c.MaxConnections < 0 || c.MaxConnectionsPerIP < 0 {
+		return fmt.Errorf("connection limits must not be negative")
+	}
+	return nil
+}
+
+// SetupMode controls when the terminal setup screen is shown
+type SetupMode int
+
+const (
+	SetupAuto   SetupMode = iota // Show only if the client negotiated nothing
+	SetupAlways                  // Always show
+	SetupNever                   // Never show
+)
+
+func (m SetupMode) String() string {
+	switch m {
+	case SetupAlways:
+		return "always"
+	case SetupNever:
+		return "never"
+	}
+	return "auto"
+}
+
+// UnmarshalText parses "auto", "always" or "never"
+func (m *SetupMode) UnmarshalText(text []byte) error {
+	switch strings.ToLower(string(text)) {
+	case "auto", "":
+		*m = SetupAuto
+	case "always":
+		*m = SetupAlways
+	case "never":
+		*m = SetupNever
+	default:
+		return fmt.Errorf("invalid setup mode %q (want auto, always or never)", text)
+	}
+	return nil
+}
+
+// Set implements flag.Value
+func (m *SetupMode) Set(str string) error {
+	return m.UnmarshalText([]byte(str))
+}
@@ -0,0 +1,81 @@
+package gemini
+
+import (
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// decodeText converts a text body to UTF-8. declared is the charset
+// parameter from the response's Meta; if it is empty or unknown and the
+// body isn't valid UTF-8, the charset is guessed.
+func decodeText(body []byte, declared string) string {
+	if declared != "" {
+		if enc, err := htmlindex.Get(declared); err == nil {
+			return decodeWith(enc, body)
+		}
+	}
+	if utf8.Valid(body) {
+		return string(body)
+	}
+	// A body cut off at the size limit can end part way through a
+	// character, which doesn't make the rest of it any less UTF-8
+	if trimmed := trimPartialRune(body); utf8.Valid(trimmed) {
+		return string(trimmed)
+	}
+	return decodeWith(guessCharset(body), body)
+}
+
+// trimPartialRune removes an incomplete UTF-8 sequence from the end of body
+func trimPartialRune(body []byte) []byte {
+	for i := len(body) - 1; i >= 0 && i >= len(body)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(body[i]) {
+			if !utf8.FullRune(body[i:]) {
+				return body[:i]
+			}
+			break
+		}
+	}
+	return body
+}
+
+// decodeWith decodes body, falling back to the raw bytes if it can't
+func decodeWith(enc encoding.Encoding, body []byte) string {
+	decoded, err := enc.NewDecoder().Bytes(body)
+	if err != nil {
+		return string(body)
+	}
+	return string(decoded)
+}
+
+// guessCharset picks a single-byte charset for a body that isn't UTF-8.
+// Western text has the odd accented letter among plain ASCII, while
+// Cyrillic text is mostly high bytes. Russian is mostly lower case, which
+// KOI8-R puts at 0xC0-0xDF and Windows-1251 at 0xE0-0xFF.
+func guessCharset(body []byte) encoding.Encoding {
+	var ascii, high, koiLower, cp1251Lower int
+	for _, b := range body {
+		switch {
+		case b >= 'A' && b <= 'Z', b >= 'a' && b <= 'z':
+			ascii++
+		case b >= 0xc0 && b <= 0xdf:
+			high++
+			koiLower++
+		case b >= 0xe0:
+			high++
+			cp1251Lower++
+		case b >= 0x80:
+			high++
+		}
+	}
+
+	if high < ascii {
+		return charmap.Windows1252
+	}
+	if koiLower > cp1251Lower {
+		return charmap.KOI8R
+	}
+	return charmap.Windows1251
+}
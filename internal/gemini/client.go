@@ -2,21 +2,102 @@ package gemini
 
 import (
 	"bufio"
+	"bytes"
+	"context"
 	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
 	"io"
+	"mime"
+	"net"
 	"net/url"
+	"os"
 	"strings"
+	"time"
 )
 
 type Response struct {
 	StatusCode int
 	Meta       string
-	Body       string
+	Body       string // Text bodies are converted to UTF-8
+	Truncated  bool   // Body was cut off at the client's MaxBodySize
+
+	// For success responses, the media type from Meta, lowercased, and its
+	// parameters such as "charset" and "lang"
+	MediaType string
+	Params    map[string]string
+}
+
+// IsText reports whether the response is a text/* type
+func (r *Response) IsText() bool {
+	return strings.HasPrefix(r.MediaType, "text/")
+}
+
+// parseMediaType splits a success response's Meta into its media type and
+// parameters. An empty Meta means text/gemini.
+func parseMediaType(meta string) (string, map[string]string) {
+	if strings.TrimSpace(meta) == "" {
+		return "text/gemini", map[string]string{}
+	}
+	mediaType, params, err := mime.ParseMediaType(meta)
+	if err != nil {
+		// Keep whatever type we can make out, ignoring broken parameters
+		mediaType, _, _ = strings.Cut(meta, ";")
+		mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+		params = map[string]string{}
+	}
+	return mediaType, params
+}
+
+// readChunkSize is how much of the body is read between progress reports
+const readChunkSize = 16 * 1024
+
+// Errors returned when a stage of a request takes longer than the
+// client allows
+var (
+	ErrConnectTimeout   = errors.New("timed out connecting to server")
+	ErrHandshakeTimeout = errors.New("timed out during TLS handshake")
+	ErrHeaderTimeout    = errors.New("timed out waiting for response")
+	ErrBodyTimeout      = errors.New("server stopped sending the page")
+	ErrRequestTimeout   = errors.New("request took too long")
+)
+
+// Client fetches Gemini URLs, checking server certificates against a
+// Trust-On-First-Use store
+type Client struct {
+	KnownHosts *KnownHosts    // nil disables certificate checks
+	Strict     bool           // Refuse changed certificates even if they could be accepted
+	Identities *IdentityStore // Client certificates; nil disables them
+
+	MaxBodySize int64 // Bodies longer than this are truncated (0 = unlimited)
+
+	// Timeouts for each stage of a request (0 = no limit)
+	ConnectTimeout   time.Duration // Establishing the TCP connection
+	HandshakeTimeout time.Duration // Completing the TLS handshake
+	HeaderTimeout    time.Duration // Sending the request until the response header arrives
+	BodyTimeout      time.Duration // Longest gap between pieces of the body
+	RequestTimeout   time.Duration // The whole request, start to finish
+}
+
+// CertificateMismatchError is returned when a host presents a different
+// certificate from the one we trust for it
+type CertificateMismatchError struct {
+	Host        string
+	Known       HostEntry
+	Certificate *x509.Certificate
+	Strict      bool // Client is in strict mode; the new certificate can't be accepted
 }
 
-// Fetch fetches a Gemini URL and returns the response
-func Fetch(urlStr string) (*Response, error) {
+func (e *CertificateMismatchError) Error() string {
+	return fmt.Sprintf("certificate for %s has changed", e.Host)
+}
+
+// Fetch fetches a Gemini URL and returns the response, presenting
+// identity's certificate if it isn't nil. progress, if not nil, is called
+// with the number of body bytes received so far as they arrive. Cancelling
+// ctx aborts the request at any stage.
+func (c *Client) Fetch(ctx context.Context, urlStr string, identity *Identity, progress func(received int64)) (*Response, error) {
 	// Parse URL
 	u, err := url.Parse(urlStr)
 	if err != nil {
@@ -32,33 +113,127 @@ func Fetch(urlStr string) (*Response, error) {
 		host = host + ":1965"
 	}
 
-	// Connect with TLS (no certificate verification for simplicity)
-	config := &tls.Config{
-		InsecureSkipVerify: true,
+	parent := ctx
+	if c.RequestTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.RequestTimeout)
+		defer cancel()
 	}
 
-	conn, err := tls.Dial("tcp", host, config)
+	response, err := c.fetch(ctx, u, host, urlStr, identity, progress)
+	if err != nil && ctx.Err() != nil {
+		// Whatever failed was interrupted by the context
+		if parent.Err() == nil {
+			return nil, ErrRequestTimeout
+		}
+		return nil, parent.Err()
+	}
+	return response, err
+}
+
+// fetch connects to host and performs a request, applying the per-stage
+// timeouts
+func (c *Client) fetch(ctx context.Context, u *url.URL, host, urlStr string, identity *Identity, progress func(received int64)) (*Response, error) {
+	dialCtx, cancelDial := withTimeout(ctx, c.ConnectTimeout)
+	defer cancelDial()
+	dialer := &net.Dialer{}
+	rawConn, err := dialer.DialContext(dialCtx, "tcp", host)
 	if err != nil {
+		if dialCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+			return nil, ErrConnectTimeout
+		}
 		return nil, fmt.Errorf("connection failed: %w", err)
 	}
+
+	// Connect with TLS. Geminispace is mostly self-signed, so instead of
+	// CA verification the certificate is checked against known hosts.
+	// The check runs during the handshake so that an impostor is turned
+	// away before it is sent the client certificate.
+	var verifyErr error
+	config := &tls.Config{
+		InsecureSkipVerify: true,
+		ServerName:         u.Hostname(),
+		VerifyConnection: func(cs tls.ConnectionState) error {
+			verifyErr = c.verify(host, cs.PeerCertificates)
+			return verifyErr
+		},
+	}
+	if identity != nil {
+		config.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return &identity.Certificate, nil
+		}
+	}
+	conn := tls.Client(rawConn, config)
 	defer conn.Close()
 
-	// Send request (URL + CRLF)
+	// Closing the connection unblocks any read or write in progress
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	handshakeCtx, cancelHandshake := withTimeout(ctx, c.HandshakeTimeout)
+	defer cancelHandshake()
+	if err := conn.HandshakeContext(handshakeCtx); err != nil {
+		if verifyErr != nil {
+			return nil, verifyErr
+		}
+		if handshakeCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+			return nil, ErrHandshakeTimeout
+		}
+		return nil, fmt.Errorf("TLS handshake failed: %w", err)
+	}
+
+	return c.exchange(conn, urlStr, progress)
+}
+
+// withTimeout is context.WithTimeout, except that a zero timeout means
+// no limit
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// setDeadline sets conn's deadline timeout from now, or clears it if
+// timeout is zero
+func setDeadline(conn net.Conn, timeout time.Duration) {
+	if timeout <= 0 {
+		conn.SetDeadline(time.Time{})
+		return
+	}
+	conn.SetDeadline(time.Now().Add(timeout))
+}
+
+// exchange sends the request on an established connection and reads the response
+func (c *Client) exchange(conn *tls.Conn, urlStr string, progress func(received int64)) (*Response, error) {
+	// Send request (URL + CRLF). The header deadline covers both sending
+	// the request and waiting for the reply.
+	setDeadline(conn, c.HeaderTimeout)
 	request := urlStr + "\r\n"
 	if _, err := conn.Write([]byte(request)); err != nil {
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			return nil, ErrHeaderTimeout
+		}
 		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
 
 	// Read response
 	reader := bufio.NewReader(conn)
 
-	// Read header line
-	header, err := reader.ReadString('\n')
+	// Read header line. ReadSlice stops at the buffer size, so a server
+	// can't make us buffer an endless header.
+	headerBytes, err := reader.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		return nil, fmt.Errorf("response header too long")
+	}
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		return nil, ErrHeaderTimeout
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to read header: %w", err)
 	}
 
-	header = strings.TrimSpace(header)
+	header := strings.TrimSpace(string(headerBytes))
 	parts := strings.SplitN(header, " ", 2)
 	if len(parts) < 1 {
 		return nil, fmt.Errorf("invalid response header")
@@ -79,12 +254,79 @@ func Fetch(urlStr string) (*Response, error) {
 
 	// For success responses (2x), read the body
 	if statusCode >= 20 && statusCode < 30 {
-		bodyBytes, err := io.ReadAll(reader)
+		bodyBytes, truncated, err := c.readBody(conn, reader, progress)
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			return nil, ErrBodyTimeout
+		}
 		if err != nil {
 			return nil, fmt.Errorf("failed to read body: %w", err)
 		}
-		response.Body = string(bodyBytes)
+		response.Truncated = truncated
+		response.MediaType, response.Params = parseMediaType(meta)
+		if response.IsText() {
+			response.Body = decodeText(bodyBytes, response.Params["charset"])
+		} else {
+			response.Body = string(bodyBytes)
+		}
 	}
 
 	return response, nil
 }
+
+// readBody reads a response body from r in chunks, stopping at
+// MaxBodySize. conn's deadline is pushed back before each read, so a body
+// can take any length of time as long as it keeps arriving.
+func (c *Client) readBody(conn net.Conn, r io.Reader, progress func(received int64)) ([]byte, bool, error) {
+	var body bytes.Buffer
+	chunk := make([]byte, readChunkSize)
+	for {
+		setDeadline(conn, c.BodyTimeout)
+		n, err := r.Read(chunk)
+		if n > 0 {
+			if c.MaxBodySize > 0 && int64(body.Len()+n) > c.MaxBodySize {
+				body.Write(chunk[:c.MaxBodySize-int64(body.Len())])
+				return body.Bytes(), true, nil
+			}
+			body.Write(chunk[:n])
+			if progress != nil {
+				progress(int64(body.Len()))
+			}
+		}
+		if err == io.EOF {
+			return body.Bytes(), false, nil
+		}
+		if err != nil {
+			return nil, false, err
+		}
+	}
+}
+
+// verify checks a host's certificate against known hosts, trusting it if
+// the host is new or its previous certificate has expired
+func (c *Client) verify(host string, certs []*x509.Certificate) error {
+	if c.KnownHosts == nil {
+		return nil
+	}
+	if len(certs) == 0 {
+		return fmt.Errorf("server sent no certificate")
+	}
+	cert := certs[0]
+
+	known, ok := c.KnownHosts.Lookup(host)
+	if ok && known.Fingerprint == Fingerprint(cert) {
+		return nil
+	}
+	if ok && time.Now().Before(known.Expires) {
+		return &CertificateMismatchError{
+			Host:        host,
+			Known:       known,
+			Certificate: cert,
+			Strict:      c.Strict,
+		}
+	}
+
+	if err := c.KnownHosts.Trust(host, cert); err != nil {
+		return fmt.Errorf("failed to save known hosts: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,379 @@
+package gemini
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// Errors returned when opening or creating identities
+var (
+	ErrIdentityExists   = errors.New("identity already exists")
+	ErrIdentityNotFound = errors.New("no such identity")
+	ErrWrongPassphrase  = errors.New("wrong passphrase")
+	ErrInvalidIdentity  = errors.New("identity names may only contain letters, digits, '-' and '_'")
+	ErrWeakPassphrase   = errors.New("passphrase must be at least 8 characters")
+)
+
+var identityNameRe = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
+// minPassphraseLength is the shortest passphrase a new identity may have
+const minPassphraseLength = 8
+
+// Private keys are encrypted with AES-256-GCM under a key derived from
+// the passphrase with scrypt, so a copy of the identity directory is no
+// use without the passphrases. The scrypt settings are the ones
+// recommended for interactive logins.
+const (
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+
+	encryptedKeyType = "GEMNET ENCRYPTED PRIVATE KEY"
+)
+
+// passphraseRounds is how many times a passphrase was hashed by earlier
+// versions, which stored the private key unencrypted
+const passphraseRounds = 100000
+
+// Identity is a client certificate used to log in to capsules
+type Identity struct {
+	Name        string
+	Certificate tls.Certificate
+	Scopes      []string // URL prefixes the identity is used for
+}
+
+// identityMeta is the part of an identity saved alongside its certificate
+type identityMeta struct {
+	Salt       string   `json:"salt"`
+	Passphrase string   `json:"passphrase,omitempty"` // Hex hash of salt + passphrase, for unencrypted keys
+	Scopes     []string `json:"scopes"`
+}
+
+// IdentityStore keeps client identities on disk, one certificate and one
+// metadata file per identity. Identities are shared server-wide, so each
+// is protected by a passphrase chosen when it is created.
+type IdentityStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// OpenIdentityStore opens (creating if needed) an identity directory
+func OpenIdentityStore(dir string) (*IdentityStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &IdentityStore{dir: dir}, nil
+}
+
+// Create generates a new identity with a self-signed certificate
+func (st *IdentityStore) Create(name, passphrase string) (*Identity, error) {
+	if !identityNameRe.MatchString(name) {
+		return nil, ErrInvalidIdentity
+	}
+	if len(passphrase) < minPassphraseLength {
+		return nil, ErrWeakPassphrase
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if _, err := os.Stat(st.certPath(name)); err == nil {
+		return nil, ErrIdentityExists
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().AddDate(20, 0, 0),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	if err := st.saveCert(name, certPEM, keyDER, passphrase, salt); err != nil {
+		return nil, err
+	}
+
+	meta := &identityMeta{Salt: hex.EncodeToString(salt)}
+	if err := st.saveMeta(name, meta); err != nil {
+		// Without its metadata the identity can't be opened, and the
+		// certificate would keep its name taken
+		os.Remove(st.certPath(name))
+		return nil, err
+	}
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, err
+	}
+	return &Identity{Name: name, Certificate: cert}, nil
+}
+
+// Open loads an identity after checking its passphrase
+func (st *IdentityStore) Open(name, passphrase string) (*Identity, error) {
+	if !identityNameRe.MatchString(name) {
+		return nil, ErrIdentityNotFound
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	meta, err := st.loadMeta(name)
+	if os.IsNotExist(err) {
+		return nil, ErrIdentityNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	salt, err := hex.DecodeString(meta.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("identity %s: invalid salt", name)
+	}
+	data, err := os.ReadFile(st.certPath(name))
+	if err != nil {
+		return nil, err
+	}
+
+	var certPEM, keyPEM []byte
+	unencrypted := false
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		switch block.Type {
+		case "CERTIFICATE":
+			certPEM = append(certPEM, pem.EncodeToMemory(block)...)
+
+		case encryptedKeyType:
+			keyDER, err := openKey(block.Bytes, passphrase, salt)
+			if err != nil {
+				return nil, err
+			}
+			keyPEM = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+
+		case "PRIVATE KEY":
+			// Saved unencrypted by an earlier version, so only the
+			// passphrase hash protects it
+			want := []byte(meta.Passphrase)
+			got := []byte(hashPassphrase(salt, passphrase))
+			if subtle.ConstantTimeCompare(want, got) != 1 {
+				return nil, ErrWrongPassphrase
+			}
+			keyPEM = pem.EncodeToMemory(block)
+			unencrypted = true
+		}
+	}
+	if certPEM == nil || keyPEM == nil {
+		return nil, fmt.Errorf("identity %s: certificate or key missing", name)
+	}
+
+	if unencrypted {
+		// Now that the passphrase is known, encrypt the key
+		block, _ := pem.Decode(keyPEM)
+		if err := st.saveCert(name, certPEM, block.Bytes, passphrase, salt); err != nil {
+			return nil, err
+		}
+		meta.Passphrase = ""
+		if err := st.saveMeta(name, meta); err != nil {
+			return nil, err
+		}
+	}
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, err
+	}
+	return &Identity{Name: name, Certificate: cert, Scopes: meta.Scopes}, nil
+}
+
+// AddScope records that id should be used for URLs starting with scope
+func (st *IdentityStore) AddScope(id *Identity, scope string) error {
+	for _, existing := range id.Scopes {
+		if existing == scope {
+			return nil
+		}
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	meta, err := st.loadMeta(id.Name)
+	if err != nil {
+		return err
+	}
+	meta.Scopes = append(meta.Scopes, scope)
+	if err := st.saveMeta(id.Name, meta); err != nil {
+		return err
+	}
+	id.Scopes = meta.Scopes
+	return nil
+}
+
+// Matches reports how specific a match id is for a URL: the length of the
+// longest scope that covers it, or 0 if none does
+func (id *Identity) Matches(urlStr string) int {
+	best := 0
+	for _, scope := range id.Scopes {
+		if inScope(urlStr, scope) && len(scope) > best {
+			best = len(scope)
+		}
+	}
+	return best
+}
+
+// inScope reports whether urlStr is scope or lies beneath it. The match
+// has to end at a path boundary, so a scope of gemini://example.org/app
+// covers gemini://example.org/app/page but not gemini://example.org/apple.
+func inScope(urlStr, scope string) bool {
+	if !strings.HasPrefix(urlStr, scope) {
+		return false
+	}
+	if len(urlStr) == len(scope) || strings.HasSuffix(scope, "/") {
+		return true
+	}
+	next := urlStr[len(scope)]
+	return next == '/' || next == '?'
+}
+
+func (st *IdentityStore) certPath(name string) string {
+	return filepath.Join(st.dir, name+".pem")
+}
+
+func (st *IdentityStore) metaPath(name string) string {
+	return filepath.Join(st.dir, name+".json")
+}
+
+// saveCert writes an identity's certificate and its private key,
+// encrypted with the passphrase. The file is replaced in one step so a
+// failed write never loses the key.
+func (st *IdentityStore) saveCert(name string, certPEM, keyDER []byte, passphrase string, salt []byte) error {
+	sealed, err := sealKey(keyDER, passphrase, salt)
+	if err != nil {
+		return err
+	}
+	data := append(append([]byte{}, certPEM...), pem.EncodeToMemory(&pem.Block{Type: encryptedKeyType, Bytes: sealed})...)
+
+	tmp := st.certPath(name) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, st.certPath(name)); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+func (st *IdentityStore) loadMeta(name string) (*identityMeta, error) {
+	data, err := os.ReadFile(st.metaPath(name))
+	if err != nil {
+		return nil, err
+	}
+	meta := &identityMeta{}
+	if err := json.Unmarshal(data, meta); err != nil {
+		return nil, fmt.Errorf("identity %s: %w", name, err)
+	}
+	return meta, nil
+}
+
+func (st *IdentityStore) saveMeta(name string, meta *identityMeta) error {
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(st.metaPath(name), data, 0600)
+}
+
+func hashPassphrase(salt []byte, passphrase string) string {
+	sum := sha256.Sum256(append(append([]byte{}, salt...), passphrase...))
+	for i := 1; i < passphraseRounds; i++ {
+		sum = sha256.Sum256(sum[:])
+	}
+	return hex.EncodeToString(sum[:])
+}
+
+// keyCipher returns the AES-GCM cipher for a passphrase and salt
+func keyCipher(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealKey encrypts a private key, returning the nonce followed by the
+// ciphertext
+func sealKey(keyDER []byte, passphrase string, salt []byte) ([]byte, error) {
+	gcm, err := keyCipher(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, keyDER, nil), nil
+}
+
+// openKey decrypts a key sealed by sealKey. A wrong passphrase fails the
+// GCM authentication check.
+func openKey(sealed []byte, passphrase string, salt []byte) ([]byte, error) {
+	gcm, err := keyCipher(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("identity key is truncated")
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	keyDER, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	return keyDER, nil
+}
@@ -0,0 +1,134 @@
+package gemini
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HostEntry is the certificate we trust for a host
+type HostEntry struct {
+	Fingerprint string
+	Expires     time.Time
+}
+
+// KnownHosts is a Trust-On-First-Use store of host certificate
+// fingerprints, saved to a file shared by every session
+type KnownHosts struct {
+	path string
+
+	mu    sync.Mutex
+	hosts map[string]HostEntry
+}
+
+// LoadKnownHosts reads a known-hosts file. A missing file is treated as
+// empty and created on the first save.
+//
+// Each line holds a host, a certificate fingerprint and the certificate's
+// expiry as a Unix timestamp, separated by spaces.
+func LoadKnownHosts(path string) (*KnownHosts, error) {
+	k := &KnownHosts{
+		path:  path,
+		hosts: make(map[string]HostEntry),
+	}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return k, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("%s:%d: expected host, fingerprint and expiry", path, lineNum)
+		}
+		expires, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid expiry: %w", path, lineNum, err)
+		}
+		k.hosts[fields[0]] = HostEntry{
+			Fingerprint: fields[1],
+			Expires:     time.Unix(expires, 0),
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// Lookup returns the trusted certificate for a host
+func (k *KnownHosts) Lookup(host string) (HostEntry, bool) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	entry, ok := k.hosts[host]
+	return entry, ok
+}
+
+// Trust records cert as the trusted certificate for host and saves the file
+func (k *KnownHosts) Trust(host string, cert *x509.Certificate) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.hosts[host] = HostEntry{
+		Fingerprint: Fingerprint(cert),
+		Expires:     cert.NotAfter,
+	}
+	return k.save()
+}
+
+// save writes the store to disk, replacing the file atomically
+func (k *KnownHosts) save() error {
+	hosts := make([]string, 0, len(k.hosts))
+	for host := range k.hosts {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+
+	var b strings.Builder
+	b.WriteString("# gemnet known hosts: host fingerprint expiry\n")
+	for _, host := range hosts {
+		entry := k.hosts[host]
+		fmt.Fprintf(&b, "%s %s %d\n", host, entry.Fingerprint, entry.Expires.Unix())
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(k.path), ".known_hosts-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.WriteString(b.String()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), k.path)
+}
+
+// Fingerprint returns the SHA-256 fingerprint of a certificate
+func Fingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
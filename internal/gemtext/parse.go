@@ -0,0 +1,88 @@
+package gemtext
+
+import (
+	"strings"
+)
+
+// LineType identifies the kind of a gemtext line
+type LineType int
+
+const (
+	Text            LineType = iota
+	Link                     // "=> URL label"
+	Heading                  // "#", "##" or "###"
+	ListItem                 // "* "
+	Quote                    // ">"
+	PreformatToggle          // "```", with optional alt text on the opening fence
+	Preformatted             // Any line between two toggle lines
+)
+
+// Line is one parsed gemtext line
+type Line struct {
+	Type  LineType
+	Text  string // Display text with the line-type markup removed
+	URL   string // Link target (Link lines only)
+	Level int    // Heading level 1-3 (Heading lines only)
+}
+
+// Parse splits a gemtext document into typed lines. Markup is ignored
+// inside preformatted blocks.
+func Parse(body string) []Line {
+	rawLines := strings.Split(body, "\n")
+	lines := make([]Line, 0, len(rawLines))
+	preformatted := false
+
+	for _, raw := range rawLines {
+		raw = strings.TrimRight(raw, "\r")
+
+		if strings.HasPrefix(raw, "```") {
+			alt := ""
+			if !preformatted {
+				alt = strings.TrimSpace(raw[3:])
+			}
+			preformatted = !preformatted
+			lines = append(lines, Line{Type: PreformatToggle, Text: alt})
+			continue
+		}
+
+		if preformatted {
+			lines = append(lines, Line{Type: Preformatted, Text: raw})
+			continue
+		}
+
+		lines = append(lines, parseLine(raw))
+	}
+
+	return lines
+}
+
+// parseLine parses a line outside a preformatted block
+func parseLine(raw string) Line {
+	switch {
+	case strings.HasPrefix(raw, "=>"):
+		parts := strings.Fields(raw[2:])
+		if len(parts) == 0 {
+			break
+		}
+		label := parts[0]
+		if len(parts) > 1 {
+			label = strings.Join(parts[1:], " ")
+		}
+		return Line{Type: Link, Text: label, URL: parts[0]}
+
+	case strings.HasPrefix(raw, "#"):
+		level := len(raw) - len(strings.TrimLeft(raw, "#"))
+		if level > 3 {
+			level = 3
+		}
+		return Line{Type: Heading, Text: strings.TrimSpace(raw[level:]), Level: level}
+
+	case strings.HasPrefix(raw, "* "):
+		return Line{Type: ListItem, Text: strings.TrimSpace(raw[2:])}
+
+	case strings.HasPrefix(raw, ">"):
+		return Line{Type: Quote, Text: strings.TrimSpace(raw[1:])}
+	}
+
+	return Line{Type: Text, Text: raw}
+}
@@ -0,0 +1,274 @@
+package keymap
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Action is something the user can do while reading a page
+type Action int
+
+const (
+	None Action = iota
+	Up
+	Down
+	PageUp
+	PageDown
+	Follow
+	Back
+	Forward
+	ScrollLeft
+	ScrollRight
+	Goto
+	Keys
+	Quit
+)
+
+// Actions lists every bindable action in the order the key bindings
+// screen shows them
+var Actions = []Action{Up, Down, PageUp, PageDown, Follow, Back, Forward, ScrollLeft, ScrollRight, Goto, Keys, Quit}
+
+var actionNames = map[Action]string{
+	Up:          "Move up",
+	Down:        "Move down",
+	PageUp:      "Page up",
+	PageDown:    "Page down",
+	Follow:      "Follow selected link",
+	Back:        "Go back",
+	Forward:     "Go forward",
+	ScrollLeft:  "Scroll text left",
+	ScrollRight: "Scroll text right",
+	Goto:        "Enter a URL",
+	Keys:        "Key bindings",
+	Quit:        "Quit",
+}
+
+func (a Action) String() string {
+	if name, ok := actionNames[a]; ok {
+		return name
+	}
+	return "None"
+}
+
+// Key names a key press. Printable characters are themselves, control
+// characters are "C-x", ESC followed by a character is "M-x", and keys
+// that send escape sequences have names like "Up" and "PageDown".
+type Key string
+
+// Keys with names of their own
+const (
+	KeyUp        Key = "Up"
+	KeyDown      Key = "Down"
+	KeyRight     Key = "Right"
+	KeyLeft      Key = "Left"
+	KeyHome      Key = "Home"
+	KeyEnd       Key = "End"
+	KeyInsert    Key = "Insert"
+	KeyDelete    Key = "Delete"
+	KeyPageUp    Key = "PageUp"
+	KeyPageDown  Key = "PageDown"
+	KeyEnter     Key = "Enter"
+	KeyEscape    Key = "Esc"
+	KeyTab       Key = "Tab"
+	KeySpace     Key = "Space"
+	KeyBackspace Key = "Backspace"
+)
+
+// ByteKey returns the key for a byte the terminal sent
+func ByteKey(b byte) Key {
+	switch {
+	case b == '\r', b == '\n':
+		return KeyEnter
+	case b == '\t':
+		return KeyTab
+	case b == 0x1b:
+		return KeyEscape
+	case b == ' ':
+		return KeySpace
+	case b == 0x7f:
+		return KeyBackspace
+	case b >= 0x01 && b <= 0x1a:
+		return Key("C-" + string(rune('a'+b-1)))
+	case b < 0x20:
+		return Key("C-" + string(rune('@'+b)))
+	case b >= 0x80:
+		return Key(fmt.Sprintf("0x%02X", b))
+	}
+	return Key(string(rune(b)))
+}
+
+// MetaKey returns the key for ESC followed by the byte b, which is what
+// most terminals send for Alt or Meta with a key
+func MetaKey(b byte) Key {
+	return "M-" + ByteKey(b)
+}
+
+// FunctionKey returns the name of function key n
+func FunctionKey(n int) Key {
+	return Key(fmt.Sprintf("F%d", n))
+}
+
+// Modified returns the name of k pressed with modifier keys, such as
+// "C-Up" or "C-M-S-F5"
+func Modified(k Key, ctrl, alt, shift bool) Key {
+	if shift {
+		k = "S-" + k
+	}
+	if alt {
+		k = "M-" + k
+	}
+	if ctrl {
+		k = "C-" + k
+	}
+	return k
+}
+
+// Keymap binds keys to actions
+type Keymap struct {
+	Name        string
+	Description string
+
+	bindings map[Key]Action
+}
+
+// defaultBindings are the arrow and letter keys every preset starts from
+var defaultBindings = map[Key]Action{
+	KeyUp:        Up,
+	KeyDown:      Down,
+	KeyPageUp:    PageUp,
+	KeyPageDown:  PageDown,
+	KeyEnter:     Follow,
+	KeyLeft:      Back,
+	KeyBackspace: Back,
+	"C-h":        Back,
+	KeyRight:     Forward,
+	"<":          ScrollLeft,
+	",":          ScrollLeft,
+	">":          ScrollRight,
+	".":          ScrollRight,
+	"g":          Goto,
+	"G":          Goto,
+	"k":          Keys,
+	"K":          Keys,
+	"q":          Quit,
+	"Q":          Quit,
+}
+
+var Default = &Keymap{
+	Name:        "default",
+	Description: "Arrow keys",
+	bindings:    defaultBindings,
+}
+
+// Vi adds vi's hjkl movement and control-key paging
+var Vi = &Keymap{
+	Name:        "vi",
+	Description: "vi (hjkl)",
+	bindings: merge(defaultBindings, map[Key]Action{
+		"k":   Up,
+		"j":   Down,
+		"h":   Back,
+		"l":   Forward,
+		"C-b": PageUp,
+		"C-u": PageUp,
+		"C-f": PageDown,
+		"C-d": PageDown,
+	}),
+}
+
+// Emacs adds Emacs's control and meta keys
+var Emacs = &Keymap{
+	Name:        "emacs",
+	Description: "Emacs (C-n/C-p)",
+	bindings: merge(defaultBindings, map[Key]Action{
+		"C-p": Up,
+		"C-n": Down,
+		"M-v": PageUp,
+		"C-v": PageDown,
+		"C-b": Back,
+		"C-f": Forward,
+	}),
+}
+
+// WordStar adds the WordStar cursor diamond, ^E ^S ^D ^X, with ^R and
+// ^C for paging and ^A and ^F for sideways scrolling
+var WordStar = &Keymap{
+	Name:        "wordstar",
+	Description: "WordStar (^E^S^D^X)",
+	bindings: merge(defaultBindings, map[Key]Action{
+		"C-e": Up,
+		"C-x": Down,
+		"C-s": Back,
+		"C-d": Forward,
+		"C-r": PageUp,
+		"C-c": PageDown,
+		"C-a": ScrollLeft,
+		"C-f": ScrollRight,
+	}),
+}
+
+// Keymaps lists every preset in menu order
+var Keymaps = []*Keymap{Default, Vi, Emacs, WordStar}
+
+// Lookup returns the preset with the given name, or nil
+func Lookup(name string) *Keymap {
+	for _, m := range Keymaps {
+		if strings.EqualFold(m.Name, name) {
+			return m
+		}
+	}
+	return nil
+}
+
+// Action returns the action bound to k, or None
+func (m *Keymap) Action(k Key) Action {
+	return m.bindings[k]
+}
+
+// Keys returns the keys bound to an action, named keys first
+func (m *Keymap) Keys(a Action) []Key {
+	var keys []Key
+	for k, bound := range m.bindings {
+		if bound == a {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if named := len(keys[i]) > 1; named != (len(keys[j]) > 1) {
+			return named
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+// Rebind returns a copy of the keymap in which k is the only key for a.
+// The presets themselves are never changed, since every session shares
+// them.
+func (m *Keymap) Rebind(a Action, k Key) *Keymap {
+	bindings := make(map[Key]Action, len(m.bindings))
+	for key, bound := range m.bindings {
+		if bound != a {
+			bindings[key] = bound
+		}
+	}
+	bindings[k] = a
+
+	return &Keymap{
+		Name:        "custom",
+		Description: "Custom",
+		bindings:    bindings,
+	}
+}
+
+// merge combines binding tables, later ones overriding earlier ones
+func merge(tables ...map[Key]Action) map[Key]Action {
+	merged := make(map[Key]Action)
+	for _, table := range tables {
+		for k, a := range table {
+			merged[k] = a
+		}
+	}
+	return merged
+}
@@ -3,15 +3,112 @@ package server
 import (
 	"log"
 	"net"
+	"runtime/debug"
+	"sync"
 
+	"gemnet/internal/config"
+	"gemnet/internal/gemini"
 	"gemnet/internal/session"
+	"gemnet/internal/telnet"
 )
 
-func HandleConnection(conn net.Conn) {
+// Server hands incoming connections to sessions and enforces connection limits
+type Server struct {
+	cfg    *config.Config
+	client *gemini.Client
+
+	mu     sync.Mutex
+	active int
+	perIP  map[string]int
+}
+
+func New(cfg *config.Config) (*Server, error) {
+	client := &gemini.Client{
+		Strict:           cfg.StrictTOFU,
+		MaxBodySize:      cfg.MaxBodySize,
+		ConnectTimeout:   cfg.ConnectTimeout,
+		HandshakeTimeout: cfg.HandshakeTimeout,
+		HeaderTimeout:    cfg.HeaderTimeout,
+		BodyTimeout:      cfg.BodyTimeout,
+		RequestTimeout:   cfg.RequestTimeout,
+	}
+	if cfg.KnownHosts != "" {
+		knownHosts, err := gemini.LoadKnownHosts(cfg.KnownHosts)
+		if err != nil {
+			return nil, err
+		}
+		client.KnownHosts = knownHosts
+	}
+	if cfg.Identities != "" {
+		identities, err := gemini.OpenIdentityStore(cfg.Identities)
+		if err != nil {
+			return nil, err
+		}
+		client.Identities = identities
+	}
+
+	return &Server{
+		cfg:    cfg,
+		client: client,
+		perIP:  make(map[string]int),
+	}, nil
+}
+
+func (srv *Server) HandleConnection(conn net.Conn) {
 	defer conn.Close()
 
-	sess := session.New(conn)
+	ip := remoteIP(conn)
+	if !srv.acquire(ip) {
+		log.Printf("Connection limit reached, rejecting %s\n", conn.RemoteAddr())
+		conn.Write([]byte("Too many connections, please try again later.\r\n"))
+		return
+	}
+	defer srv.release(ip)
+
+	// A bug in one session shouldn't take down everyone else's
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Session panic from %s: %v\n%s", conn.RemoteAddr(), r, debug.Stack())
+		}
+	}()
+
+	sess := session.New(telnet.NewConn(conn), srv.cfg, srv.client)
 	if err := sess.Run(); err != nil {
 		log.Printf("Session error: %v\n", err)
 	}
 }
+
+// acquire reserves a connection slot for ip, returning false if a limit is reached
+func (srv *Server) acquire(ip string) bool {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	if srv.cfg.MaxConnections > 0 && srv.active >= srv.cfg.MaxConnections {
+		return false
+	}
+	if srv.cfg.MaxConnectionsPerIP > 0 && srv.perIP[ip] >= srv.cfg.MaxConnectionsPerIP {
+		return false
+	}
+	srv.active++
+	srv.perIP[ip]++
+	return true
+}
+
+func (srv *Server) release(ip string) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	srv.active--
+	srv.perIP[ip]--
+	if srv.perIP[ip] <= 0 {
+		delete(srv.perIP, ip)
+	}
+}
+
+func remoteIP(conn net.Conn) string {
+	host, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+	if err != nil {
+		return conn.RemoteAddr().String()
+	}
+	return host
+}
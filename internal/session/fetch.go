@@ -0,0 +1,179 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"gemnet/internal/gemini"
+)
+
+const (
+	// cancelPollInterval is how often the keyboard watcher checks whether
+	// the fetch it is watching has finished
+	cancelPollInterval = 100 * time.Millisecond
+
+	// progressInterval is the minimum time between progress updates
+	progressInterval = 250 * time.Millisecond
+)
+
+// errCancelled is returned by fetch when the user chose not to continue
+var errCancelled = errors.New("cancelled")
+
+// fetch fetches a URL, asking the user what to do if the host's
+// certificate has changed
+func (s *Session) fetch(urlStr string) (*gemini.Response, error) {
+	identity := s.identityFor(urlStr)
+	resp, err := s.fetchCancellable(urlStr, identity)
+
+	var mismatch *gemini.CertificateMismatchError
+	if errors.As(err, &mismatch) {
+		if !s.confirmCertificateChange(mismatch) {
+			return nil, errCancelled
+		}
+		if err := s.client.KnownHosts.Trust(mismatch.Host, mismatch.Certificate); err != nil {
+			return nil, err
+		}
+		return s.fetchCancellable(urlStr, identity)
+	}
+	return resp, err
+}
+
+// fetchCancellable fetches a URL while showing progress and watching the
+// keyboard, so the user can cancel a slow or endless response
+func (s *Session) fetchCancellable(urlStr string, identity *gemini.Identity) (*gemini.Response, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stopWatching := s.watchForCancel(cancel)
+	s.progressShown = false
+	s.progressWidth = 0
+	resp, err := s.client.Fetch(ctx, urlStr, identity, s.showProgress)
+	stopWatching()
+
+	if s.progressShown {
+		s.write([]byte("\r\n"))
+	}
+	if errors.Is(err, context.Canceled) {
+		return nil, errCancelled
+	}
+	return resp, err
+}
+
+// watchForCancel reads the keyboard in the background until the returned
+// function is called, calling cancel if the user presses ESC, q or Ctrl-C.
+// Other keys typed meanwhile are discarded.
+func (s *Session) watchForCancel(cancel context.CancelFunc) (stop func()) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	// Resizes arrive on the watcher goroutine, so hold on to the latest
+	// one and apply it once the watcher has stopped
+	var resize *[2]int
+	s.conn.OnWindowSize = func(width, height int) {
+		resize = &[2]int{width, height}
+	}
+
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+
+			// Wake up regularly to check whether the fetch has finished
+			b, err := s.readByteBefore(time.Now().Add(cancelPollInterval))
+			if errors.Is(err, os.ErrDeadlineExceeded) {
+				continue
+			}
+			if err != nil {
+				// The client has gone away; nobody is waiting for the page
+				cancel()
+				return
+			}
+
+			switch b {
+			case 0x1b:
+				// Arrow and function keys start with ESC too, so only
+				// ESC on its own cancels
+				k, err := s.readEscapeKey()
+				if err != nil {
+					cancel()
+					return
+				}
+				if k == keyEscape {
+					cancel()
+				}
+			case 'q', 'Q', 0x03:
+				cancel()
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+		s.input = nil
+		s.conn.SetReadDeadline(time.Time{})
+		s.conn.OnWindowSize = s.handleResize
+		if resize != nil {
+			s.handleResize(resize[0], resize[1])
+		}
+	}
+}
+
+// showProgress updates the "Received N KB" line while a body downloads,
+// at most every progressInterval so slow terminals aren't flooded
+func (s *Session) showProgress(received int64) {
+	now := time.Now()
+	if s.progressShown && now.Sub(s.lastProgress) < progressInterval {
+		return
+	}
+	s.lastProgress = now
+	s.progressShown = true
+
+	text := fmt.Sprintf("Received %d KB (ESC to cancel)", received/1024)
+	s.returnToLineStart(s.progressWidth)
+	if len(text) < s.progressWidth {
+		s.eraseLine()
+	}
+	s.write([]byte(text))
+	s.progressWidth = len(text)
+}
+
+// confirmCertificateChange warns that a host's certificate has changed
+// and returns true if the user chose to trust the new one
+func (s *Session) confirmCertificateChange(mismatch *gemini.CertificateMismatchError) bool {
+	s.write([]byte(s.term.ClearScreen))
+	s.write([]byte("WARNING: CERTIFICATE CHANGED\r\n"))
+	s.write([]byte(strings.Repeat("-", s.terminalWidth-1) + "\r\n\r\n"))
+	s.write([]byte(fmt.Sprintf("The certificate for %s is not the one\r\n", mismatch.Host)))
+	s.write([]byte("seen on earlier visits. The site may have replaced it,\r\n"))
+	s.write([]byte("or someone may be intercepting the connection.\r\n\r\n"))
+	s.write([]byte("Trusted certificate:\r\n"))
+	s.write([]byte(fmt.Sprintf("  %s\r\n", mismatch.Known.Fingerprint)))
+	s.write([]byte(fmt.Sprintf("  expires %s\r\n", mismatch.Known.Expires.Format("2006-01-02"))))
+	s.write([]byte("New certificate:\r\n"))
+	s.write([]byte(fmt.Sprintf("  %s\r\n", gemini.Fingerprint(mismatch.Certificate))))
+	s.write([]byte(fmt.Sprintf("  expires %s\r\n\r\n", mismatch.Certificate.NotAfter.Format("2006-01-02"))))
+
+	if mismatch.Strict {
+		s.write([]byte("This server does not accept changed certificates.\r\n"))
+		s.write([]byte("Press any key to continue..."))
+		s.readKey()
+		return false
+	}
+
+	s.write([]byte("Press A to accept the new certificate, or any other key to abort."))
+	b, err := s.readKey()
+	if err != nil {
+		return false
+	}
+	s.lastByte = b
+	return b == 'a' || b == 'A'
+}
@@ -0,0 +1,200 @@
+package session
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"gemnet/internal/gemini"
+)
+
+// Wrong passphrases are answered more slowly each time, and after
+// maxPassphraseAttempts the session can't open identities any more
+const (
+	maxPassphraseAttempts = 5
+	wrongPassphraseDelay  = 2 * time.Second
+)
+
+// errTooManyAttempts is returned once a session has used up its attempts
+var errTooManyAttempts = errors.New("too many wrong passphrases; reconnect to try again")
+
+// identityFor returns the unlocked identity whose scope best matches a URL
+func (s *Session) identityFor(urlStr string) *gemini.Identity {
+	var best *gemini.Identity
+	bestLen := 0
+	for _, id := range s.identities {
+		if n := id.Matches(urlStr); n > bestLen {
+			best = id
+			bestLen = n
+		}
+	}
+	return best
+}
+
+// chooseIdentity lets the user pick or create an identity for a capsule
+// that asked for a client certificate. It returns true if an identity is
+// now in use for urlStr.
+func (s *Session) chooseIdentity(urlStr, meta string) bool {
+	s.write([]byte("Client certificate required\r\n"))
+	if meta != "" {
+		s.write([]byte(s.encodeText(meta) + "\r\n"))
+	}
+	s.write([]byte("\r\n  1) Use an existing identity\r\n"))
+	s.write([]byte("  2) Create a new identity\r\n"))
+	s.write([]byte("Choice (ESC to cancel): "))
+
+	choice, ok, err := s.readLine(false)
+	if err != nil || !ok {
+		return false
+	}
+
+	var id *gemini.Identity
+	switch strings.TrimSpace(choice) {
+	case "1":
+		id, err = s.openIdentity()
+	case "2":
+		id, err = s.createIdentity()
+	default:
+		return false
+	}
+	if errors.Is(err, errCancelled) {
+		return false
+	}
+	if err != nil {
+		s.write([]byte(fmt.Sprintf("\r\nError: %v\r\n", err)))
+		s.write([]byte("Press any key to continue..."))
+		s.readKey()
+		return false
+	}
+
+	scope, ok := s.chooseScope(urlStr)
+	if !ok {
+		return false
+	}
+	if err := s.client.Identities.AddScope(id, scope); err != nil {
+		s.write([]byte(fmt.Sprintf("\r\nError: %v\r\n", err)))
+		s.write([]byte("Press any key to continue..."))
+		s.readKey()
+		return false
+	}
+
+	s.useIdentity(id)
+	return true
+}
+
+// openIdentity asks for the name and passphrase of an existing identity
+func (s *Session) openIdentity() (*gemini.Identity, error) {
+	if s.failedOpens >= maxPassphraseAttempts {
+		return nil, errTooManyAttempts
+	}
+	s.write([]byte("\r\nIdentity name: "))
+	name, ok, err := s.readLine(false)
+	if err != nil || !ok {
+		return nil, errCancelled
+	}
+	s.write([]byte("\r\nPassphrase: "))
+	passphrase, ok, err := s.readLine(true)
+	if err != nil || !ok {
+		return nil, errCancelled
+	}
+	id, err := s.client.Identities.Open(strings.TrimSpace(name), passphrase)
+	if errors.Is(err, gemini.ErrWrongPassphrase) {
+		s.failedOpens++
+		time.Sleep(time.Duration(s.failedOpens) * wrongPassphraseDelay)
+	}
+	return id, err
+}
+
+// createIdentity asks for a name and passphrase and creates a new identity
+func (s *Session) createIdentity() (*gemini.Identity, error) {
+	s.write([]byte("\r\nNew identity name (letters, digits, - and _): "))
+	name, ok, err := s.readLine(false)
+	if err != nil || !ok {
+		return nil, errCancelled
+	}
+	s.write([]byte("\r\nIdentities are shared on this server, so choose a\r\n"))
+	s.write([]byte("passphrase to keep others from using yours.\r\n"))
+	s.write([]byte("Passphrase: "))
+	passphrase, ok, err := s.readLine(true)
+	if err != nil || !ok {
+		return nil, errCancelled
+	}
+	s.write([]byte("\r\nRepeat passphrase: "))
+	repeat, ok, err := s.readLine(true)
+	if err != nil || !ok {
+		return nil, errCancelled
+	}
+	if repeat != passphrase {
+		return nil, fmt.Errorf("passphrases don't match")
+	}
+
+	s.write([]byte("\r\nCreating identity..."))
+	return s.client.Identities.Create(strings.TrimSpace(name), passphrase)
+}
+
+// chooseScope asks which URLs an identity should be used for: the whole
+// host, the current directory, or just this page
+func (s *Session) chooseScope(urlStr string) (string, bool) {
+	u, err := url.Parse(urlStr)
+	if err != nil {
+		return "", false
+	}
+	u.RawQuery = ""
+	u.Fragment = ""
+	page := u.String()
+
+	pagePath := u.Path
+	u.Path = "/"
+	host := u.String()
+	u.Path = pagePath[:strings.LastIndex(pagePath, "/")+1]
+	dir := u.String()
+
+	scopes := []string{host}
+	if dir != host && strings.HasPrefix(dir, host) {
+		scopes = append(scopes, dir)
+	}
+	if page != scopes[len(scopes)-1] {
+		scopes = append(scopes, page)
+	}
+
+	choice, err := s.askChoice("\r\nUse this identity for", scopes, 0)
+	if err != nil {
+		return "", false
+	}
+	return scopes[choice], true
+}
+
+// switchIdentity offers to replace the identity in use for urlStr after
+// the capsule refused it. It returns true if a new identity was chosen.
+func (s *Session) switchIdentity(urlStr, meta string) bool {
+	s.write([]byte("Press I to use a different identity, or any other key to continue..."))
+	b, err := s.readKey()
+	if err != nil || (b != 'i' && b != 'I') {
+		return false
+	}
+	s.write([]byte("\r\n\r\n"))
+
+	// Stop using the refused identity in this session
+	refused := s.identityFor(urlStr)
+	for i, id := range s.identities {
+		if id == refused {
+			s.identities = append(s.identities[:i], s.identities[i+1:]...)
+			break
+		}
+	}
+	return s.chooseIdentity(urlStr, meta)
+}
+
+// useIdentity makes id active in this session, replacing any earlier
+// copy of the same identity
+func (s *Session) useIdentity(id *gemini.Identity) {
+	for i, existing := range s.identities {
+		if existing.Name == id.Name {
+			s.identities[i] = id
+			return
+		}
+	}
+	s.identities = append(s.identities, id)
+}
@@ -1,166 +1,170 @@
 package session
 
 import (
+	"errors"
 	"fmt"
+	"os"
 	"strings"
+	"time"
+
+	"gemnet/internal/keymap"
 )
 
 func (s *Session) handleInput(b byte) error {
-	// Check if we're in a special input mode
-	if s.inputMode == "goto" {
-		return s.handleGotoInput(b)
+	if s.term.Teletype {
+		return s.handleTeletypeInput(b)
 	}
 
-	// Handle escape sequences
-	if b == 0x1b { // ESC
-		// Read next bytes for escape sequence
-		seq := make([]byte, 2)
-		s.conn.Read(seq)
-
-		if seq[0] == '[' {
-			switch seq[1] {
-			case 'A': // Up arrow
-				oldScroll := s.scrollOffset
-				s.handleArrowKey(-1)
-				if s.scrollOffset == oldScroll {
-					s.renderPartialLinkUpdate()
-				} else {
-					s.render()
-				}
-			case 'B': // Down arrow
-				oldScroll := s.scrollOffset
-				s.handleArrowKey(1)
-				if s.scrollOffset == oldScroll {
-					s.renderPartialLinkUpdate()
-				} else {
-					s.render()
-				}
-			case 'C': // Right arrow - forward in history
-				s.navigateForward()
-				s.render()
-			case 'D': // Left arrow - back in history
-				s.navigateBack()
-				s.render()
-			case '5': // Page Up
-				s.conn.Read(make([]byte, 1)) // Read trailing ~
-				s.scrollPageWithDirection(-1)
-				s.render()
-			case '6': // Page Down
-				s.conn.Read(make([]byte, 1)) // Read trailing ~
-				s.scrollPageWithDirection(1)
-				s.render()
-			}
-		}
+	// LF - ignore if it immediately follows CR (CRLF handling); LF alone
+	// (some clients send just LF) is Enter
+	if b == '\n' && s.lastByte == '\r' {
+		s.lastByte = b
 		return nil
 	}
 
-	switch b {
-	case 'g', 'G': // Go to URL
+	// Digits always start a link number, whatever the keymap
+	if b >= '0' && b <= '9' {
 		s.lastByte = b
-		s.inputMode = "goto"
-		s.inputBuffer = ""
-		s.write([]byte("\r\n\x1b[KEnter Gemini URL: "))
-		return nil
+		return s.followLinkByNumber(b)
+	}
 
-	case '\r': // Enter - follow selected link
-		s.lastByte = '\r'
-		if s.selectedLink >= 0 && s.selectedLink < len(s.links) {
-			link := s.links[s.selectedLink]
-			s.navigateTo(link.URL)
-		}
-		return nil
+	k, err := s.keyFor(b)
+	if err != nil {
+		return err
+	}
+	s.lastByte = b
+	return s.perform(s.keys.Action(k))
+}
 
-	case '\n': // LF - ignore if it immediately follows CR (CRLF handling)
-		if s.lastByte == '\r' {
-			// This is part of CRLF, ignore it
-			s.lastByte = '\n'
-			return nil
+// perform carries out an action from the keymap
+func (s *Session) perform(action keymap.Action) error {
+	switch action {
+	case keymap.Up, keymap.Down:
+		delta := 1
+		if action == keymap.Up {
+			delta = -1
 		}
-		// LF alone (some clients send just LF)
-		s.lastByte = '\n'
+		oldScroll := s.scrollOffset
+		s.handleArrowKey(delta)
+		if s.scrollOffset == oldScroll {
+			s.renderPartialLinkUpdate()
+		} else {
+			s.render()
+		}
+
+	case keymap.PageUp:
+		s.scrollPageWithDirection(-1)
+		s.render()
+
+	case keymap.PageDown:
+		s.scrollPageWithDirection(1)
+		s.render()
+
+	case keymap.Follow:
 		if s.selectedLink >= 0 && s.selectedLink < len(s.links) {
 			link := s.links[s.selectedLink]
 			s.navigateTo(link.URL)
 		}
-		return nil
 
-	case 0x7f, 0x08: // Backspace/Delete - go back
-		s.lastByte = b
+	case keymap.Back:
 		s.navigateBack()
 		s.render()
-		return nil
 
-	case 'q', 'Q': // Quit
-		return fmt.Errorf("user quit")
+	case keymap.Forward:
+		s.navigateForward()
+		s.render()
 
-	default:
-		s.lastByte = b
-	}
+	case keymap.ScrollLeft: // Preformatted text
+		s.scrollHorizontal(-1)
+		s.render()
+
+	case keymap.ScrollRight:
+		s.scrollHorizontal(1)
+		s.render()
+
+	case keymap.Goto:
+		return s.promptGoto()
+
+	case keymap.Keys:
+		return s.editKeys()
 
+	case keymap.Quit:
+		return fmt.Errorf("user quit")
+	}
 	return nil
 }
 
-func (s *Session) handleGotoInput(b byte) error {
-	switch b {
-	case '\r': // Submit
-		s.lastByte = '\r'
-		s.inputMode = ""
-		url := strings.TrimSpace(s.inputBuffer)
-		if url != "" {
-			// Add gemini:// prefix if not present
-			if !strings.HasPrefix(url, "gemini://") {
-				url = "gemini://" + url
-			}
-			s.navigateTo(url)
-		} else {
-			s.render()
-		}
-		return nil
+// errIdleTimeout is returned when the client sends nothing for longer
+// than the configured idle timeout
+var errIdleTimeout = errors.New("idle timeout")
+
+// readByte blocks until the next byte arrives from the client, or the
+// idle timeout expires
+func (s *Session) readByte() (byte, error) {
+	var deadline time.Time
+	if s.cfg.IdleTimeout > 0 {
+		deadline = time.Now().Add(s.cfg.IdleTimeout)
+	}
+	b, err := s.readByteBefore(deadline)
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		return 0, errIdleTimeout
+	}
+	return b, err
+}
+
+// readByteBefore reads the next byte, giving up at deadline (zero means
+// wait forever)
+func (s *Session) readByteBefore(deadline time.Time) (byte, error) {
+	if len(s.input) > 0 {
+		b := s.input[0]
+		s.input = s.input[1:]
+		return b, nil
+	}
 
-	case '\n': // LF - ignore if it immediately follows CR (CRLF handling)
-		if s.lastByte == '\r' {
-			// This is part of CRLF, ignore it
-			s.lastByte = '\n'
-			return nil
+	s.conn.SetReadDeadline(deadline)
+	defer s.conn.SetReadDeadline(time.Time{})
+
+	buf := make([]byte, 1)
+	for {
+		n, err := s.conn.Read(buf)
+		if err != nil {
+			return 0, err
 		}
-		// LF alone (some clients send just LF)
-		s.lastByte = '\n'
-		s.inputMode = ""
-		url := strings.TrimSpace(s.inputBuffer)
-		if url != "" {
-			// Add gemini:// prefix if not present
-			if !strings.HasPrefix(url, "gemini://") {
-				url = "gemini://" + url
-			}
-			s.navigateTo(url)
-		} else {
-			s.render()
+		if n > 0 {
+			return s.translateInput(buf[0]), nil
 		}
-		return nil
+	}
+}
 
-	case 0x1b: // ESC - cancel
-		s.lastByte = b
-		s.inputMode = ""
-		s.inputBuffer = ""
-		s.render()
-		return nil
+// translateInput converts a byte from the terminal's own keys into what
+// an ANSI terminal would send, queueing any further bytes of the sequence
+func (s *Session) translateInput(b byte) byte {
+	t, ok := s.term.Input[b]
+	if !ok || t == "" {
+		return b
+	}
+	s.input = append(s.input, t[1:]...)
+	return t[0]
+}
 
-	case 0x7f, 0x08: // Backspace
-		s.lastByte = b
-		if len(s.inputBuffer) > 0 {
-			s.inputBuffer = s.inputBuffer[:len(s.inputBuffer)-1]
-			s.write([]byte("\b \b")) // Erase character
-		}
-		return nil
+// promptGoto asks for a URL and navigates to it
+func (s *Session) promptGoto() error {
+	s.writeMessageLine("Enter Gemini URL: ")
+	input, ok, err := s.readLine(false)
+	if err != nil {
+		return err
+	}
 
-	default:
-		// Add printable characters to buffer
-		if b >= 32 && b < 127 {
-			s.inputBuffer += string(b)
-			s.write([]byte{b})
-		}
-		s.lastByte = b
+	url := strings.TrimSpace(input)
+	if !ok || url == "" {
+		s.render()
+		return nil
 	}
 
+	// Add gemini:// prefix if not present
+	if !strings.HasPrefix(url, "gemini://") {
+		url = "gemini://" + url
+	}
+	s.navigateTo(url)
 	return nil
 }
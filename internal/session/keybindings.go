@@ -0,0 +1,112 @@
+package session
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gemnet/internal/keymap"
+)
+
+// keymapFor returns the preset with the given name, or the default one
+func keymapFor(name string) *keymap.Keymap {
+	if m := keymap.Lookup(name); m != nil {
+		return m
+	}
+	return keymap.Default
+}
+
+// editKeys shows the key bindings screen, where the user can switch to
+// another preset or rebind actions for the rest of the session
+func (s *Session) editKeys() error {
+	for {
+		s.write([]byte(s.term.ClearScreen))
+		s.write([]byte(fmt.Sprintf("KEY BINDINGS: %s\r\n", s.keys.Description)))
+		s.write([]byte(strings.Repeat("-", s.terminalWidth-1) + "\r\n"))
+		for i, action := range keymap.Actions {
+			keys := s.keys.Keys(action)
+			names := make([]string, len(keys))
+			for j, k := range keys {
+				names[j] = string(k)
+			}
+			s.write([]byte(fmt.Sprintf("%2d) %-20s %s\r\n", i+1, action, strings.Join(names, " "))))
+		}
+		s.write([]byte("\r\nDigits always follow links by number.\r\n"))
+		s.write([]byte("Type a number to rebind, P for presets, or Enter to return: "))
+
+		answer, ok, err := s.readLine(false)
+		if err != nil {
+			return err
+		}
+		answer = strings.TrimSpace(answer)
+		if !ok || answer == "" {
+			s.render()
+			return nil
+		}
+
+		if strings.EqualFold(answer, "p") {
+			if err := s.choosePreset(); err != nil {
+				return err
+			}
+			continue
+		}
+
+		n, convErr := strconv.Atoi(answer)
+		if convErr != nil || n < 1 || n > len(keymap.Actions) {
+			continue
+		}
+		if err := s.rebind(keymap.Actions[n-1]); err != nil {
+			return err
+		}
+	}
+}
+
+// choosePreset switches to one of the built-in keymaps
+func (s *Session) choosePreset() error {
+	names := make([]string, len(keymap.Keymaps))
+	current := 0
+	for i, m := range keymap.Keymaps {
+		names[i] = m.Description
+		if m == s.keys {
+			current = i
+		}
+	}
+	choice, err := s.askChoice("Preset", names, current)
+	if err != nil {
+		return err
+	}
+	s.keys = keymap.Keymaps[choice]
+	return nil
+}
+
+// rebind asks for a new key for an action, which replaces the keys it
+// had. ESC on its own cancels.
+func (s *Session) rebind(action keymap.Action) error {
+	s.writeMessageLine(fmt.Sprintf("Press the new key for %s (ESC cancels): ", action))
+
+	for {
+		b, err := s.readByte()
+		if err != nil {
+			return err
+		}
+		if b == '\n' && s.lastByte == '\r' {
+			s.lastByte = b
+			continue // Second half of CR LF
+		}
+		s.lastByte = b
+
+		k, err := s.keyFor(b)
+		if err != nil {
+			return err
+		}
+		switch {
+		case k == keymap.KeyEscape:
+			return nil
+		case k == "", b >= '0' && b <= '9':
+			continue // Unknown sequences and digits can't be bound
+		}
+
+		s.keys = s.keys.Rebind(action, k)
+		return nil
+	}
+}
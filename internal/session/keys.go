@@ -0,0 +1,332 @@
+package session
+
+import (
+	"errors"
+	"os"
+	"time"
+
+	"gemnet/internal/keymap"
+)
+
+// Keys decoded from escape sequences. Any of them may have modifier bits
+// added for the Shift, Alt and Ctrl keys held with it.
+type key int
+
+const (
+	keyNone key = iota
+	keyEscape
+	keyUp
+	keyDown
+	keyRight
+	keyLeft
+	keyHome
+	keyEnd
+	keyInsert
+	keyDelete
+	keyPageUp
+	keyPageDown
+	keyF1  // keyF1+n-1 is Fn, up to keyF12
+	keyF12 = keyF1 + 11
+
+	// keyMeta plus a character is ESC followed by that character, which
+	// is how most terminals send Alt or Meta with a key
+	keyMeta key = 0x100
+
+	keyShift     key = 0x1000
+	keyAlt       key = 0x2000
+	keyCtrl      key = 0x4000
+	keyModifiers     = keyShift | keyAlt | keyCtrl
+)
+
+// escapeTimeout is how long to wait after ESC before deciding the user
+// pressed ESC on its own rather than a key that sends a sequence
+const escapeTimeout = 300 * time.Millisecond
+
+// readByteTimeout waits up to timeout for the next byte. It returns
+// ok=false if nothing arrived in time.
+func (s *Session) readByteTimeout(timeout time.Duration) (b byte, ok bool, err error) {
+	b, err = s.readByteBefore(time.Now().Add(timeout))
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return b, true, nil
+}
+
+// maxSequenceLength is the longest escape sequence decoded; anything
+// longer is junk and is thrown away
+const maxSequenceLength = 32
+
+// readEscapeKey reads the rest of an escape sequence after ESC and returns
+// the key it encodes. It decodes CSI (ESC [) and SS3 (ESC O) sequences
+// with xterm modifier parameters, the rxvt and Linux console variants,
+// and VT52 keys (ESC A-D, ESC P-S). A lone ESC returns keyEscape, ESC
+// followed by another character returns keyMeta plus the character, and
+// unknown sequences return keyNone.
+func (s *Session) readEscapeKey() (key, error) {
+	b, ok, err := s.readByteTimeout(escapeTimeout)
+	if err != nil || !ok {
+		return keyEscape, err
+	}
+
+	switch {
+	case b == '[':
+		return s.readCSI()
+	case b == 'O':
+		return s.readSS3()
+	case b == 0x1b:
+		// Alt with a key that sends a sequence, or ESC pressed twice
+		b, ok, err = s.readByteTimeout(escapeTimeout)
+		switch {
+		case err != nil:
+			return keyNone, err
+		case ok && b == '[':
+			k, err := s.readCSI()
+			return withAlt(k), err
+		case ok && b == 'O':
+			k, err := s.readSS3()
+			return withAlt(k), err
+		case ok:
+			s.unreadByte(b)
+		}
+		return keyEscape, nil
+	case b >= 'A' && b <= 'D':
+		return vt52Arrows[b-'A'], nil
+	case b >= 'P' && b <= 'S':
+		return keyF1 + key(b-'P'), nil // VT52 PF1-PF4
+	case b < 0x80:
+		return keyMeta + key(b), nil
+	}
+	return keyNone, nil
+}
+
+// vt52Arrows are the keys for ESC A to ESC D, the same letters ANSI
+// terminals end their arrow sequences with
+var vt52Arrows = []key{keyUp, keyDown, keyRight, keyLeft}
+
+// readCSI decodes the rest of a control sequence after ESC [
+func (s *Session) readCSI() (key, error) {
+	params, final, err := s.readSequence()
+	if err != nil || final == 0 {
+		return keyNone, err
+	}
+
+	switch final {
+	case 'A', 'B', 'C', 'D':
+		return vt52Arrows[final-'A'] | modifiers(params, 1), nil
+	case 'H':
+		return keyHome | modifiers(params, 1), nil
+	case 'F':
+		return keyEnd | modifiers(params, 1), nil
+	case 'P', 'Q', 'R', 'S':
+		return keyF1 + key(final-'P') | modifiers(params, 1), nil
+	case 'a', 'b', 'c', 'd':
+		return vt52Arrows[final-'a'] | keyShift, nil // rxvt
+	case '~':
+		return tildeKey(params) | modifiers(params, 1), nil
+	case '$':
+		return tildeKey(params) | keyShift, nil // rxvt
+	case '^':
+		return tildeKey(params) | keyCtrl, nil // rxvt
+	case '@':
+		return tildeKey(params) | keyCtrl | keyShift, nil // rxvt
+	case '[':
+		// The Linux console sends F1-F5 as ESC [ [ A-E
+		b, ok, err := s.readByteTimeout(escapeTimeout)
+		if err != nil || !ok || b < 'A' || b > 'E' {
+			return keyNone, err
+		}
+		return keyF1 + key(b-'A'), nil
+	}
+	return keyNone, nil
+}
+
+// readSS3 decodes the rest of a sequence after ESC O, which terminals
+// send for arrows in application cursor mode and for F1-F4
+func (s *Session) readSS3() (key, error) {
+	params, final, err := s.readSequence()
+	if err != nil || final == 0 {
+		return keyNone, err
+	}
+
+	// Some terminals send the modifier as the only parameter
+	mod := 1
+	if len(params) == 1 {
+		mod = 0
+	}
+
+	switch final {
+	case 'A', 'B', 'C', 'D':
+		return vt52Arrows[final-'A'] | modifiers(params, mod), nil
+	case 'H':
+		return keyHome | modifiers(params, mod), nil
+	case 'F':
+		return keyEnd | modifiers(params, mod), nil
+	case 'P', 'Q', 'R', 'S':
+		return keyF1 + key(final-'P') | modifiers(params, mod), nil
+	case 'a', 'b', 'c', 'd':
+		return vt52Arrows[final-'a'] | keyCtrl, nil // rxvt
+	case 'M':
+		// Enter on the numeric keypad
+		s.unreadByte('\r')
+	}
+	return keyNone, nil
+}
+
+// readSequence reads the parameters and final byte of a CSI or SS3
+// sequence. Parameters are numbers separated by ';', with 0 for any left
+// out. It returns a zero final byte if the sequence was cut short or
+// isn't a key; a control character arriving mid-sequence is left to be
+// read as a key of its own.
+func (s *Session) readSequence() (params []int, final byte, err error) {
+	param, private := 0, false
+	for n := 0; n < maxSequenceLength; n++ {
+		b, ok, err := s.readByteTimeout(escapeTimeout)
+		if err != nil || !ok {
+			return nil, 0, err
+		}
+
+		switch {
+		case b >= '0' && b <= '9':
+			param = param*10 + int(b-'0')
+		case b == ';':
+			params = append(params, param)
+			param = 0
+		case b >= 0x3a && b <= 0x3f:
+			private = true // Mouse and other reports we never asked for
+		case b == '$':
+			// Final byte of rxvt's shifted keys, although it looks like
+			// an intermediate byte
+			return append(params, param), b, nil
+		case b >= 0x20 && b <= 0x2f:
+			// Intermediate bytes; no keys use them
+		case b >= 0x40 && b <= 0x7e:
+			if private {
+				return nil, 0, nil
+			}
+			return append(params, param), b, nil
+		default:
+			s.unreadByte(b)
+			return nil, 0, nil
+		}
+	}
+	return nil, 0, nil
+}
+
+// tildeKey returns the key for a sequence ending in '~' (or rxvt's '$',
+// '^' and '@') from its first parameter
+func tildeKey(params []int) key {
+	switch params[0] {
+	case 1, 7:
+		return keyHome
+	case 2:
+		return keyInsert
+	case 3:
+		return keyDelete
+	case 4, 8:
+		return keyEnd
+	case 5:
+		return keyPageUp
+	case 6:
+		return keyPageDown
+	case 11, 12, 13, 14, 15:
+		return keyF1 + key(params[0]-11)
+	case 17, 18, 19, 20, 21:
+		return keyF1 + 5 + key(params[0]-17)
+	case 23, 24:
+		return keyF1 + 10 + key(params[0]-23)
+	}
+	return keyNone
+}
+
+// modifiers returns the modifier bits in params[i], which xterm sends as
+// 1 plus 1 for Shift, 2 for Alt, 4 for Ctrl and 8 for Meta
+func modifiers(params []int, i int) key {
+	if i >= len(params) || params[i] < 2 {
+		return 0
+	}
+	m := params[i] - 1
+	var mods key
+	if m&1 != 0 {
+		mods |= keyShift
+	}
+	if m&(2|8) != 0 {
+		mods |= keyAlt
+	}
+	if m&4 != 0 {
+		mods |= keyCtrl
+	}
+	return mods
+}
+
+// withAlt adds Alt to a decoded key, unless the sequence wasn't a key
+func withAlt(k key) key {
+	if k == keyNone {
+		return k
+	}
+	return k | keyAlt
+}
+
+// unreadByte puts a byte back to be read again next
+func (s *Session) unreadByte(b byte) {
+	s.input = append([]byte{b}, s.input...)
+}
+
+// readKey waits for a single key press for a one-key prompt and returns
+// its byte. ESC on its own returns ESC; other keys that send escape
+// sequences are read in full, so they aren't taken for later keys, and
+// return 0.
+func (s *Session) readKey() (byte, error) {
+	b, err := s.readByte()
+	if err != nil || b != 0x1b {
+		return b, err
+	}
+	k, err := s.readEscapeKey()
+	if err != nil || k != keyEscape {
+		return 0, err
+	}
+	return b, nil
+}
+
+// keyNames are the keymap names of the keys decoded from escape sequences
+var keyNames = map[key]keymap.Key{
+	keyEscape:   keymap.KeyEscape,
+	keyUp:       keymap.KeyUp,
+	keyDown:     keymap.KeyDown,
+	keyRight:    keymap.KeyRight,
+	keyLeft:     keymap.KeyLeft,
+	keyHome:     keymap.KeyHome,
+	keyEnd:      keymap.KeyEnd,
+	keyInsert:   keymap.KeyInsert,
+	keyDelete:   keymap.KeyDelete,
+	keyPageUp:   keymap.KeyPageUp,
+	keyPageDown: keymap.KeyPageDown,
+}
+
+// keyFor returns the key a byte read from the terminal starts, reading
+// the rest of the escape sequence if it is ESC. It returns "" for
+// sequences it doesn't recognise.
+func (s *Session) keyFor(b byte) (keymap.Key, error) {
+	if b != 0x1b {
+		return keymap.ByteKey(b), nil
+	}
+	k, err := s.readEscapeKey()
+	if err != nil {
+		return "", err
+	}
+	if k >= keyMeta && k < keyShift {
+		return keymap.MetaKey(byte(k - keyMeta)), nil
+	}
+
+	base := k &^ keyModifiers
+	name, ok := keyNames[base]
+	if base >= keyF1 && base <= keyF12 {
+		name, ok = keymap.FunctionKey(int(base-keyF1)+1), true
+	}
+	if !ok {
+		return "", nil
+	}
+	return keymap.Modified(name, k&keyCtrl != 0, k&keyAlt != 0, k&keyShift != 0), nil
+}
@@ -0,0 +1,199 @@
+package session
+
+import (
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"gemnet/internal/config"
+	"gemnet/internal/keymap"
+	"gemnet/internal/telnet"
+	"gemnet/internal/terminal"
+)
+
+// scriptedConn is a net.Conn that returns its input a byte at a time
+// and then times out, as if the user stopped typing
+type scriptedConn struct {
+	net.Conn
+	input []byte
+}
+
+func (c *scriptedConn) Read(p []byte) (int, error) {
+	if len(c.input) == 0 {
+		return 0, os.ErrDeadlineExceeded
+	}
+	p[0] = c.input[0]
+	c.input = c.input[1:]
+	return 1, nil
+}
+
+func (c *scriptedConn) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+func (c *scriptedConn) SetReadDeadline(time.Time) error {
+	return nil
+}
+
+// typed returns a session reading the given bytes from the terminal
+func typed(profile *terminal.Profile, input string) *Session {
+	s := New(telnet.NewConn(&scriptedConn{input: []byte(input)}), config.Default(), nil)
+	s.term = profile
+	return s
+}
+
+func TestKeyFor(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  keymap.Key
+		rest  string // Bytes left to be read as the next keys
+	}{
+		{"printable", "a", "a", ""},
+		{"control", "\x01", "C-a", ""},
+		{"enter", "\r", keymap.KeyEnter, ""},
+		{"backspace", "\x7f", keymap.KeyBackspace, ""},
+
+		{"lone ESC times out", "\x1b", keymap.KeyEscape, ""},
+		{"ESC twice", "\x1b\x1b", keymap.KeyEscape, ""},
+		{"ESC twice then a key", "\x1b\x1bx", keymap.KeyEscape, "x"},
+		{"meta", "\x1bx", "M-x", ""},
+		{"meta control", "\x1b\x01", "M-C-a", ""},
+
+		{"CSI arrow", "\x1b[A", keymap.KeyUp, ""},
+		{"CSI home", "\x1b[H", keymap.KeyHome, ""},
+		{"CSI end", "\x1b[F", keymap.KeyEnd, ""},
+		{"CSI ctrl arrow", "\x1b[1;5A", "C-Up", ""},
+		{"CSI shift arrow", "\x1b[1;2C", "S-Right", ""},
+		{"CSI alt arrow", "\x1b[1;3B", "M-Down", ""},
+		{"CSI meta counts as alt", "\x1b[1;9B", "M-Down", ""},
+		{"CSI all modifiers", "\x1b[1;8D", "C-M-S-Left", ""},
+		{"CSI page up", "\x1b[5~", keymap.KeyPageUp, ""},
+		{"CSI ctrl page down", "\x1b[6;5~", "C-PageDown", ""},
+		{"CSI home tilde", "\x1b[1~", keymap.KeyHome, ""},
+		{"CSI end tilde", "\x1b[4~", keymap.KeyEnd, ""},
+		{"CSI insert", "\x1b[2~", keymap.KeyInsert, ""},
+		{"CSI delete", "\x1b[3~", keymap.KeyDelete, ""},
+		{"CSI F5", "\x1b[15~", "F5", ""},
+		{"CSI F12", "\x1b[24~", "F12", ""},
+		{"CSI shift F1", "\x1b[1;2P", "S-F1", ""},
+		{"alt with a CSI key", "\x1b\x1b[A", "M-Up", ""},
+
+		{"SS3 arrow", "\x1bOD", keymap.KeyLeft, ""},
+		{"SS3 F1", "\x1bOP", "F1", ""},
+		{"SS3 modifier only", "\x1bO5A", "C-Up", ""},
+		{"SS3 with modifier", "\x1bO1;2Q", "S-F2", ""},
+		{"alt with an SS3 key", "\x1b\x1bOB", "M-Down", ""},
+		{"SS3 keypad enter", "\x1bOM", "", "\r"},
+
+		{"VT52 arrow", "\x1bB", keymap.KeyDown, ""},
+		{"VT52 PF4", "\x1bS", "F4", ""},
+
+		{"rxvt shift arrow", "\x1b[a", "S-Up", ""},
+		{"rxvt ctrl arrow", "\x1bOd", "C-Left", ""},
+		{"rxvt shift insert", "\x1b[2$", "S-Insert", ""},
+		{"rxvt ctrl page up", "\x1b[5^", "C-PageUp", ""},
+		{"rxvt ctrl shift delete", "\x1b[3@", "C-S-Delete", ""},
+		{"Linux console F3", "\x1b[[C", "F3", ""},
+
+		{"cut short by the timeout", "\x1b[1;", "", ""},
+		{"cut short by a control key", "\x1b[1\r", "", "\r"},
+		{"unknown tilde key", "\x1b[99~", "", ""},
+		{"mouse report", "\x1b[<0;1;1M", "", ""},
+		{"too long", "\x1b[" + strings.Repeat("1", maxSequenceLength) + "A", "", "A"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := typed(terminal.ANSI, tt.input)
+			b, err := s.readByte()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := s.keyFor(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("key %q, want %q", got, tt.want)
+			}
+
+			var rest []byte
+			for {
+				b, ok, err := s.readByteTimeout(escapeTimeout)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !ok {
+					break
+				}
+				rest = append(rest, b)
+			}
+			if string(rest) != tt.rest {
+				t.Errorf("left %q, want %q", rest, tt.rest)
+			}
+		})
+	}
+}
+
+func TestKeyForTranslatedInput(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile *terminal.Profile
+		input   string
+		want    keymap.Key
+	}{
+		{"PETSCII cursor up", terminal.PETSCII, "\x91", keymap.KeyUp},
+		{"PETSCII left arrow key is ESC", terminal.PETSCII, "\x5f", keymap.KeyEscape},
+		{"PETSCII F7", terminal.PETSCII, "\x88", keymap.KeyPageDown},
+		{"ATASCII cursor right", terminal.ATASCII, "\x1f", keymap.KeyRight},
+		{"ATASCII return", terminal.ATASCII, "\x9b", keymap.KeyEnter},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := typed(tt.profile, tt.input)
+			b, err := s.readByte()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := s.keyFor(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("key %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadKey(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  byte
+	}{
+		{"letter", "y", 'y'},
+		{"lone ESC", "\x1b", 0x1b},
+		{"arrow key is read whole", "\x1b[A", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := typed(terminal.ANSI, tt.input)
+			got, err := s.readKey()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			if len(s.input) != 0 {
+				t.Errorf("left %q unread", s.input)
+			}
+		})
+	}
+}
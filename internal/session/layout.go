@@ -0,0 +1,226 @@
+package session
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"gemnet/internal/gemtext"
+	"gemnet/internal/terminal"
+)
+
+// layout is the current page wrapped for the screen. It is built once per
+// page and terminal setup so scrolling and link selection don't re-wrap
+// the whole page on every keystroke.
+type layout struct {
+	// Settings the layout was built for
+	width   int
+	hScroll int
+	term    *terminal.Profile
+
+	segments  [][]string // Display lines for each content line
+	start     []int      // First display line of each content line; start[len(content)] is the total
+	linkLines []int      // First display line of each link
+}
+
+// getLayout returns the page layout, rebuilding it if the page or
+// terminal settings have changed since it was built
+func (s *Session) getLayout() *layout {
+	l := s.layout
+	if l != nil && l.width == s.terminalWidth && l.hScroll == s.hScroll && l.term == s.term {
+		return l
+	}
+
+	l = &layout{
+		width:     s.terminalWidth,
+		hScroll:   s.hScroll,
+		term:      s.term,
+		segments:  make([][]string, len(s.content)),
+		start:     make([]int, len(s.content)+1),
+		linkLines: make([]int, len(s.links)),
+	}
+	for i := range s.content {
+		l.segments[i] = s.buildSegments(i)
+		l.start[i+1] = l.start[i] + len(l.segments[i])
+	}
+	for i, link := range s.links {
+		l.linkLines[i] = l.start[link.Line]
+	}
+
+	s.layout = l
+	return l
+}
+
+// invalidateLayout discards the cached layout after the page changes
+func (s *Session) invalidateLayout() {
+	s.layout = nil
+}
+
+// displaySegments returns the display lines for a content line
+func (s *Session) displaySegments(contentLineIdx int) []string {
+	return s.getLayout().segments[contentLineIdx]
+}
+
+// linkDisplayLine returns the first display line of a link
+func (s *Session) linkDisplayLine(linkIdx int) int {
+	return s.getLayout().linkLines[linkIdx]
+}
+
+// contentLineToDisplayLine converts a content line index to its first display line index
+func (s *Session) contentLineToDisplayLine(contentLineIdx int) int {
+	if contentLineIdx < 0 {
+		return 0
+	}
+	if contentLineIdx > len(s.content) {
+		contentLineIdx = len(s.content)
+	}
+	return s.getLayout().start[contentLineIdx]
+}
+
+// getTotalDisplayLines returns the total number of display lines
+func (s *Session) getTotalDisplayLines() int {
+	return s.getLayout().start[len(s.content)]
+}
+
+// displayLineToContentLine returns the content line that contains a display line
+func (s *Session) displayLineToContentLine(displayLine int) int {
+	if len(s.content) == 0 {
+		return 0
+	}
+	start := s.getLayout().start
+	// First content line that starts after displayLine, minus one
+	idx := sort.Search(len(s.content), func(i int) bool {
+		return start[i+1] > displayLine
+	})
+	if idx >= len(s.content) {
+		return len(s.content) - 1
+	}
+	return idx
+}
+
+// wrapLine wraps a line to width columns, breaking at spaces. Words longer
+// than the width are broken wherever they reach the edge.
+func (s *Session) wrapLine(line string, width int) []string {
+	if width < 1 {
+		width = 1
+	}
+	if utf8.RuneCountInString(line) <= width {
+		return []string{line}
+	}
+
+	// Work in runes so multi-byte UTF-8 characters are never split
+	runes := []rune(line)
+	var wrapped []string
+	for len(runes) > width {
+		// Break at the last space that leaves the segment within width
+		cut := -1
+		for i := width; i > 0; i-- {
+			if runes[i] == ' ' {
+				cut = i
+				break
+			}
+		}
+
+		segment := runes[:width]
+		rest := runes[width:]
+		if cut > 0 && strings.TrimLeft(string(runes[:cut]), " ") != "" {
+			segment = runes[:cut]
+			rest = runes[cut:]
+		}
+		wrapped = append(wrapped, strings.TrimRight(string(segment), " "))
+
+		// Spaces at the break are dropped rather than starting the next line
+		for len(rest) > 0 && rest[0] == ' ' {
+			rest = rest[1:]
+		}
+		runes = rest
+	}
+	if len(runes) > 0 {
+		wrapped = append(wrapped, string(runes))
+	}
+	return wrapped
+}
+
+// wrapIndented wraps a line with first prepended to the first display line
+// and rest prepended to each following one
+func (s *Session) wrapIndented(line, first, rest string) []string {
+	width := s.terminalWidth - 1 - utf8.RuneCountInString(first)
+	wrapped := s.wrapLine(line, width)
+	for i := range wrapped {
+		if i == 0 {
+			wrapped[i] = first + wrapped[i]
+		} else {
+			wrapped[i] = rest + wrapped[i]
+		}
+	}
+	return wrapped
+}
+
+// buildSegments wraps a content line into display lines, decorated for
+// its line type
+func (s *Session) buildSegments(contentLineIdx int) []string {
+	line := s.content[contentLineIdx]
+	width := s.terminalWidth - 1
+
+	switch line.Type {
+	case gemtext.Heading:
+		return s.headingSegments(line)
+	case gemtext.ListItem:
+		return s.wrapIndented(line.Text, "* ", "  ")
+	case gemtext.Quote:
+		return s.wrapIndented(line.Text, "| ", "| ")
+	case gemtext.Link:
+		// Indent wrapped labels under the text, past the "[n] " prefix
+		if end := strings.Index(line.Text, "] "); end >= 0 {
+			prefix := line.Text[:end+2]
+			return s.wrapIndented(line.Text[end+2:], prefix, strings.Repeat(" ", len(prefix)))
+		}
+	case gemtext.Preformatted:
+		return []string{s.preformattedSegment(line.Text)}
+	}
+	return s.wrapLine(line.Text, width)
+}
+
+// headingSegments renders a heading. H1 is upper case with a double rule
+// under it, H2 has a single rule, and H3 relies on bold alone; terminals
+// without bold keep the "###" marker so H3 stands out from body text.
+func (s *Session) headingSegments(line ContentLine) []string {
+	width := s.terminalWidth - 1
+	switch line.Level {
+	case 1:
+		wrapped := s.wrapLine(strings.ToUpper(line.Text), width)
+		return append(wrapped, headingRule("=", wrapped))
+	case 2:
+		wrapped := s.wrapLine(line.Text, width)
+		return append(wrapped, headingRule("-", wrapped))
+	}
+	if s.term.Bold == "" {
+		return s.wrapIndented(line.Text, "### ", "    ")
+	}
+	return s.wrapLine(line.Text, width)
+}
+
+// headingRule returns a rule as wide as the widest wrapped heading line
+func headingRule(char string, wrapped []string) string {
+	widest := 0
+	for _, w := range wrapped {
+		if n := utf8.RuneCountInString(w); n > widest {
+			widest = n
+		}
+	}
+	return strings.Repeat(char, widest)
+}
+
+// preformattedSegment returns a preformatted line, which is never wrapped;
+// it is cut to the screen width starting at the horizontal scroll offset
+func (s *Session) preformattedSegment(text string) string {
+	runes := []rune(text)
+	if s.hScroll >= len(runes) {
+		return ""
+	}
+	runes = runes[s.hScroll:]
+	if len(runes) > s.terminalWidth-1 {
+		runes = runes[:s.terminalWidth-1]
+	}
+	return string(runes)
+}
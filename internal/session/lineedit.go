@@ -0,0 +1,204 @@
+package session
+
+import (
+	"strings"
+)
+
+// lineEditor holds the text and cursor of a single-line prompt. Each edit
+// returns the bytes needed to update the echoed line, using only
+// backspace to move left so it works on any terminal.
+type lineEditor struct {
+	buf    []byte
+	pos    int  // Cursor position in buf
+	masked bool // Echo '*' instead of the typed characters
+}
+
+// text returns the current contents of the line
+func (e *lineEditor) text() string {
+	return string(e.buf)
+}
+
+// display returns how a run of characters is echoed
+func (e *lineEditor) display(b []byte) string {
+	if e.masked {
+		return strings.Repeat("*", len(b))
+	}
+	return string(b)
+}
+
+// insert adds a character at the cursor
+func (e *lineEditor) insert(b byte) string {
+	e.buf = append(e.buf, 0)
+	copy(e.buf[e.pos+1:], e.buf[e.pos:])
+	e.buf[e.pos] = b
+	e.pos++
+
+	// Print the new character and everything after it, then back up
+	tail := e.display(e.buf[e.pos:])
+	return e.display([]byte{b}) + tail + strings.Repeat("\b", len(tail))
+}
+
+// backspace deletes the character before the cursor
+func (e *lineEditor) backspace() string {
+	if e.pos == 0 {
+		return ""
+	}
+	e.pos--
+	return "\b" + e.deleteAt(e.pos, 1)
+}
+
+// deleteForward deletes the character under the cursor
+func (e *lineEditor) deleteForward() string {
+	if e.pos >= len(e.buf) {
+		return ""
+	}
+	return e.deleteAt(e.pos, 1)
+}
+
+// deleteWord deletes the word before the cursor, along with any spaces
+// between it and the cursor
+func (e *lineEditor) deleteWord() string {
+	start := e.pos
+	for start > 0 && e.buf[start-1] == ' ' {
+		start--
+	}
+	for start > 0 && e.buf[start-1] != ' ' {
+		start--
+	}
+	n := e.pos - start
+	if n == 0 {
+		return ""
+	}
+	e.pos = start
+	return strings.Repeat("\b", n) + e.deleteAt(start, n)
+}
+
+// killLine deletes the whole line
+func (e *lineEditor) killLine() string {
+	out := e.home()
+	return out + e.deleteAt(0, len(e.buf))
+}
+
+// killToEnd deletes from the cursor to the end of the line
+func (e *lineEditor) killToEnd() string {
+	return e.deleteAt(e.pos, len(e.buf)-e.pos)
+}
+
+// deleteAt removes n characters at index i, where the cursor already sits,
+// and redraws the rest of the line over them
+func (e *lineEditor) deleteAt(i, n int) string {
+	if n <= 0 {
+		return ""
+	}
+	e.buf = append(e.buf[:i], e.buf[i+n:]...)
+	tail := e.display(e.buf[i:])
+	return tail + strings.Repeat(" ", n) + strings.Repeat("\b", len(tail)+n)
+}
+
+func (e *lineEditor) left() string {
+	if e.pos == 0 {
+		return ""
+	}
+	e.pos--
+	return "\b"
+}
+
+func (e *lineEditor) right() string {
+	if e.pos >= len(e.buf) {
+		return ""
+	}
+	// Reprinting the character moves the cursor past it
+	e.pos++
+	return e.display(e.buf[e.pos-1 : e.pos])
+}
+
+func (e *lineEditor) home() string {
+	out := strings.Repeat("\b", e.pos)
+	e.pos = 0
+	return out
+}
+
+func (e *lineEditor) end() string {
+	out := e.display(e.buf[e.pos:])
+	e.pos = len(e.buf)
+	return out
+}
+
+// readLine runs a line editor until the user presses Enter or ESC,
+// echoing '*' for each character if masked is set. It returns ok=false
+// if the prompt was cancelled.
+func (s *Session) readLine(masked bool) (line string, ok bool, err error) {
+	e := lineEditor{masked: masked}
+	for {
+		b, err := s.readByte()
+		if err != nil {
+			return "", false, err
+		}
+
+		out := ""
+		switch b {
+		case '\r':
+			s.lastByte = b
+			return e.text(), true, nil
+
+		case '\n': // LF - ignore if it immediately follows CR (CRLF handling)
+			if s.lastByte == '\r' {
+				s.lastByte = b
+				continue
+			}
+			s.lastByte = b
+			return e.text(), true, nil
+
+		case 0x1b: // ESC - arrow keys, or cancel on its own
+			k, err := s.readEscapeKey()
+			if err != nil {
+				return "", false, err
+			}
+			switch k {
+			case keyEscape:
+				s.lastByte = b
+				return "", false, nil
+			case keyLeft:
+				out = e.left()
+			case keyRight:
+				out = e.right()
+			case keyHome:
+				out = e.home()
+			case keyEnd:
+				out = e.end()
+			case keyDelete:
+				out = e.deleteForward()
+			}
+
+		case 0x7f, 0x08: // Backspace
+			out = e.backspace()
+		case 0x01: // Ctrl-A
+			out = e.home()
+		case 0x02: // Ctrl-B
+			out = e.left()
+		case 0x04: // Ctrl-D
+			out = e.deleteForward()
+		case 0x05: // Ctrl-E
+			out = e.end()
+		case 0x06: // Ctrl-F
+			out = e.right()
+		case 0x0b: // Ctrl-K
+			out = e.killToEnd()
+		case 0x15: // Ctrl-U
+			out = e.killLine()
+		case 0x17: // Ctrl-W
+			out = e.deleteWord()
+
+		default:
+			// Add printable characters at the cursor
+			if b >= 32 && b < 127 {
+				out = e.insert(b)
+			}
+		}
+		s.lastByte = b
+
+		if s.echo && out != "" {
+			s.write([]byte(out))
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package session
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// linkNumberTimeout is how long to wait for another digit before
+// following the link typed so far
+const linkNumberTimeout = 1500 * time.Millisecond
+
+// followLinkByNumber reads a link number starting with the digit first and
+// follows that link, whatever is on screen. The number ends with Enter, a
+// pause, or as soon as another digit couldn't make a valid link number.
+// ESC cancels, and any other key cancels and is then handled as usual.
+func (s *Session) followLinkByNumber(first byte) error {
+	digits := []byte{first}
+	s.showLinkNumber(digits, 0)
+
+	for s.couldBeLonger(digits) {
+		b, ok, err := s.readByteTimeout(linkNumberTimeout)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			break // Timed out; use what we have
+		}
+
+		if b == '\r' || b == '\n' {
+			s.lastByte = b
+			break
+		}
+
+		shown := len(digits)
+		switch {
+		case b >= '0' && b <= '9':
+			digits = append(digits, b)
+		case b == 0x7f || b == 0x08:
+			digits = digits[:len(digits)-1]
+		case b == 0x1b:
+			if _, err := s.readEscapeKey(); err != nil {
+				return err
+			}
+			digits = nil
+		default:
+			// Leave the key for the main loop
+			s.unreadByte(b)
+			digits = nil
+		}
+		s.lastByte = b
+
+		if len(digits) == 0 {
+			s.clearLinkNumber()
+			return nil
+		}
+		s.showLinkNumber(digits, shown)
+	}
+
+	n, _ := strconv.Atoi(string(digits))
+	if n >= len(s.links) {
+		s.showLinkNumber([]byte(fmt.Sprintf("%s - no such link", digits)), len(digits))
+
+		// Leave the message up until a key is pressed or a moment passes.
+		// The key is then handled as usual.
+		b, ok, err := s.readByteTimeout(linkNumberTimeout)
+		if err != nil {
+			return err
+		}
+		if ok {
+			s.unreadByte(b)
+		}
+		s.clearLinkNumber()
+		return nil
+	}
+
+	s.selectedLink = n
+	s.navigateTo(s.links[n].URL)
+	return nil
+}
+
+// couldBeLonger reports whether another digit could still make a valid
+// link number, so it's worth waiting for one
+func (s *Session) couldBeLonger(digits []byte) bool {
+	n, _ := strconv.Atoi(string(digits))
+	return n > 0 && n*10 < len(s.links)
+}
+
+// showLinkNumber echoes the number typed so far in the status area below
+// the page. Without cursor addressing it is written on a fresh line and
+// then updated in place, given how many characters were already shown.
+func (s *Session) showLinkNumber(text []byte, shown int) {
+	if s.term.CanAddress() {
+		s.write([]byte(s.term.MoveTo(s.terminalHeight, 1)))
+		s.eraseLine()
+		s.write([]byte("Link: " + string(text)))
+		return
+	}
+
+	if shown == 0 {
+		s.writeMessageLine("Link: " + string(text))
+		return
+	}
+
+	// Back up over the old number and write the new one over it
+	s.write([]byte(strings.Repeat("\b", shown) + string(text)))
+	if erased := shown - len(text); erased > 0 {
+		s.write([]byte(strings.Repeat(" ", erased) + strings.Repeat("\b", erased)))
+	}
+}
+
+// clearLinkNumber removes the link number prompt after it is cancelled or
+// the number turns out not to be a link
+func (s *Session) clearLinkNumber() {
+	if s.term.CanAddress() {
+		s.write([]byte(s.term.MoveTo(s.terminalHeight, 1)))
+		s.eraseLine()
+		return
+	}
+	s.render()
+}
@@ -0,0 +1,155 @@
+package session
+
+import (
+	"fmt"
+	"strings"
+
+	"gemnet/internal/gemini"
+	"gemnet/internal/gemtext"
+	"gemnet/internal/telnet"
+)
+
+// showBody replaces the page content with a response body, choosing how
+// to display it from the response's media type
+func (s *Session) showBody(resp *gemini.Response) {
+	switch {
+	case resp.MediaType == "text/gemini":
+		s.parseContent(resp.Body)
+	case resp.IsText():
+		s.parsePlainText(resp.Body)
+	default:
+		s.parseHexDump(resp)
+	}
+	if resp.Truncated {
+		s.markTruncated()
+	}
+}
+
+// clearContent empties the page before new content is loaded
+func (s *Session) clearContent(capacity int) {
+	s.content = make([]ContentLine, 0, capacity)
+	s.links = make([]Link, 0)
+	s.selectedLink = 0
+	s.hScroll = 0
+	s.listed = 0
+	s.invalidateLayout()
+}
+
+// parsePlainText loads a non-gemtext text body. Lines are shown exactly
+// as sent, without wrapping or markup.
+func (s *Session) parsePlainText(body string) {
+	body = strings.TrimSuffix(strings.ReplaceAll(body, "\r\n", "\n"), "\n")
+	lines := strings.Split(body, "\n")
+	s.clearContent(len(lines))
+
+	for _, line := range lines {
+		s.content = append(s.content, ContentLine{
+			Type: gemtext.Preformatted,
+			Text: s.encodeText(line),
+		})
+	}
+}
+
+// parseHexDump loads a hex dump of a binary body, with as many bytes per
+// line as fit the terminal
+func (s *Session) parseHexDump(resp *gemini.Response) {
+	perLine := 16
+	if s.terminalWidth < 78 {
+		perLine = 8
+	}
+	body := []byte(resp.Body)
+	s.clearContent(len(body)/perLine + 3)
+
+	s.content = append(s.content,
+		ContentLine{Type: gemtext.Text, Text: fmt.Sprintf("[%s, %d bytes]", resp.MediaType, len(body))},
+		ContentLine{Type: gemtext.Text},
+	)
+
+	for offset := 0; offset < len(body); offset += perLine {
+		chunk := body[offset:min(offset+perLine, len(body))]
+
+		var line strings.Builder
+		fmt.Fprintf(&line, "%08x ", offset)
+		for i := 0; i < perLine; i++ {
+			if i < len(chunk) {
+				fmt.Fprintf(&line, " %02x", chunk[i])
+			} else {
+				line.WriteString("   ")
+			}
+		}
+		line.WriteString("  |")
+		for _, b := range chunk {
+			if b < 0x20 || b > 0x7e {
+				b = '.'
+			}
+			line.WriteByte(b)
+		}
+		line.WriteString("|")
+
+		s.content = append(s.content, ContentLine{
+			Type: gemtext.Preformatted,
+			Text: line.String(),
+		})
+	}
+}
+
+// confirmBinary shows what a non-text response contains and lets the user
+// download it or view it as a hex dump. It returns true if the hex dump
+// should be shown.
+func (s *Session) confirmBinary(urlStr string, resp *gemini.Response) bool {
+	s.write([]byte(s.term.ClearScreen))
+	s.write([]byte("BINARY FILE\r\n"))
+	s.write([]byte(strings.Repeat("-", s.terminalWidth-1) + "\r\n\r\n"))
+	s.write([]byte(s.encodeText(urlStr) + "\r\n\r\n"))
+	s.write([]byte(fmt.Sprintf("Type: %s\r\n", resp.MediaType)))
+	size := fmt.Sprintf("%d bytes", len(resp.Body))
+	if resp.Truncated {
+		size += " (truncated)"
+	}
+	s.write([]byte(fmt.Sprintf("Size: %s\r\n\r\n", size)))
+	s.write([]byte("This can't be shown as a page.\r\n\r\n"))
+	s.write([]byte("  D) Download\r\n"))
+	s.write([]byte("  H) View as hex dump\r\n"))
+	s.write([]byte("  Any other key to go back\r\n"))
+
+	b, err := s.readKey()
+	if err != nil {
+		return false
+	}
+	switch b {
+	case 'h', 'H':
+		return true
+	case 'd', 'D':
+		s.download(resp)
+	}
+	return false
+}
+
+// download sends a body to the terminal as raw bytes, for the user to
+// save with their terminal program's capture or logging feature
+func (s *Session) download(resp *gemini.Response) {
+	// Ask for binary mode so the client passes every byte through untouched
+	s.conn.Will(telnet.OptBinary)
+	s.conn.Negotiate(negotiationTimeout)
+
+	s.write([]byte(s.term.ClearScreen))
+	s.write([]byte("DOWNLOAD\r\n"))
+	s.write([]byte(strings.Repeat("-", s.terminalWidth-1) + "\r\n\r\n"))
+	s.write([]byte("Start your terminal's capture or logging now, then press\r\n"))
+	s.write([]byte("any key to send the file. Press ESC to cancel.\r\n\r\n"))
+	if !s.conn.LocalEnabled(telnet.OptBinary) {
+		s.write([]byte("Your terminal did not agree to binary mode, so some\r\n"))
+		s.write([]byte("bytes may be changed on the way.\r\n\r\n"))
+	}
+
+	b, err := s.readKey()
+	if err != nil || b == 0x1b {
+		return
+	}
+
+	// Written directly so line endings aren't translated
+	s.conn.Write([]byte(resp.Body))
+
+	s.write([]byte(fmt.Sprintf("\r\n\r\nSent %d bytes. Stop capturing, then press any key...", len(resp.Body))))
+	s.readKey()
+}
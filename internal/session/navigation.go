@@ -1,12 +1,12 @@
 package session
 
 import (
+	"errors"
 	"fmt"
 	"net/url"
 	"strings"
 
-	"gemnet/internal/gemini"
-	"gemnet/internal/util"
+	"gemnet/internal/gemtext"
 )
 
 func (s *Session) navigateTo(urlStr string) {
@@ -21,14 +21,17 @@ func (s *Session) navigateTo(urlStr string) {
 		}
 	}
 
-	s.write([]byte(fmt.Sprintf("\r\n\x1b[KFetching %s...\r\n", urlStr)))
+	s.writeMessageLine(fmt.Sprintf("Fetching %s... (ESC to cancel)\r\n", urlStr))
 
-	resp, err := gemini.Fetch(urlStr)
+	resp, err := s.fetch(urlStr)
+	if errors.Is(err, errCancelled) {
+		s.render()
+		return
+	}
 	if err != nil {
 		s.write([]byte(fmt.Sprintf("Error: %v\r\n", err)))
 		s.write([]byte("Press any key to continue..."))
-		buf := make([]byte, 1)
-		s.conn.Read(buf)
+		s.readKey()
 		s.render()
 		return
 	}
@@ -39,11 +42,43 @@ func (s *Session) navigateTo(urlStr string) {
 		return
 	}
 
+	if resp.StatusCode == 10 || resp.StatusCode == 11 {
+		// Input requested; status 11 is sensitive input
+		s.promptInput(urlStr, resp.Meta, resp.StatusCode == 11)
+		return
+	}
+
+	if resp.StatusCode == 60 && s.client.Identities != nil {
+		// Client certificate required
+		if s.chooseIdentity(urlStr, resp.Meta) {
+			s.navigateTo(urlStr)
+		} else {
+			s.render()
+		}
+		return
+	}
+
 	if resp.StatusCode < 20 || resp.StatusCode >= 30 {
 		s.write([]byte(fmt.Sprintf("Error: Status %d - %s\r\n", resp.StatusCode, resp.Meta)))
+		if explanation := statusExplanation(resp.StatusCode); explanation != "" {
+			s.write([]byte(explanation + "\r\n"))
+		}
+		if (resp.StatusCode == 61 || resp.StatusCode == 62) && s.identityFor(urlStr) != nil {
+			// Let the user switch to another identity
+			if s.switchIdentity(urlStr, resp.Meta) {
+				s.navigateTo(urlStr)
+			} else {
+				s.render()
+			}
+			return
+		}
 		s.write([]byte("Press any key to continue..."))
-		buf := make([]byte, 1)
-		s.conn.Read(buf)
+		s.readKey()
+		s.render()
+		return
+	}
+
+	if !resp.IsText() && !s.confirmBinary(urlStr, resp) {
 		s.render()
 		return
 	}
@@ -73,7 +108,7 @@ func (s *Session) navigateTo(urlStr string) {
 
 	// Parse new content
 	s.currentURL = urlStr
-	s.parseContent(resp.Body)
+	s.showBody(resp)
 	s.scrollOffset = 0
 	s.selectedLink = 0
 
@@ -89,54 +124,100 @@ func (s *Session) navigateTo(urlStr string) {
 	s.render()
 }
 
-func (s *Session) parseContent(body string) {
-	// Convert UTF-8 to ASCII
-	asciiBody := util.UTF8ToASCII(body)
+// statusExplanation returns a plain-language hint for failure statuses
+// whose Meta text may not make the problem clear
+func statusExplanation(status int) string {
+	switch status {
+	case 60:
+		return "This page needs a client certificate, which is disabled on this server."
+	case 61:
+		return "Your identity is not authorised for this page. Another identity may be."
+	case 62:
+		return "The server rejected your identity's certificate as invalid."
+	}
+	return ""
+}
 
-	// Split into lines
-	lines := strings.Split(asciiBody, "\n")
-	s.content = make([]string, 0, len(lines))
-	s.links = make([]Link, 0)
-	s.headerLines = make(map[int]bool)
-	s.selectedLink = 0 // Reset selected link when parsing new content
+// promptInput asks the user for the input a capsule requested and
+// resubmits the URL with the answer as its query
+func (s *Session) promptInput(urlStr, prompt string, sensitive bool) {
+	if prompt == "" {
+		prompt = "Input"
+	}
+	s.write([]byte(s.encodeText(prompt) + "\r\n"))
+	s.write([]byte("> "))
+
+	answer, ok, err := s.readLine(sensitive)
+	if err != nil || !ok || answer == "" {
+		s.render()
+		return
+	}
+
+	u, err := url.Parse(urlStr)
+	if err != nil {
+		s.render()
+		return
+	}
+	// Gemini wants spaces as %20 rather than the form-style '+'
+	u.RawQuery = strings.ReplaceAll(url.QueryEscape(answer), "+", "%20")
+	u.Fragment = ""
+	s.navigateTo(u.String())
+}
+
+// encodeText replaces characters the client's charset can't show
+func (s *Session) encodeText(text string) string {
+	return s.charset.Transliterate(text)
+}
+
+func (s *Session) parseContent(body string) {
+	lines := gemtext.Parse(body)
+	s.clearContent(len(lines))
 
 	linkIndex := 0
 	for _, line := range lines {
-		line = strings.TrimRight(line, "\r")
-
-		// Check if this is a header line
-		if strings.HasPrefix(line, "#") {
-			s.headerLines[len(s.content)] = true
-		} else if strings.HasPrefix(line, "=>") {
-			// Check if this is a link line
-			// Parse link
-			linkText := strings.TrimSpace(line[2:])
-			parts := strings.Fields(linkText)
-			if len(parts) > 0 {
-				linkURL := parts[0]
-				linkLabel := linkURL
-				if len(parts) > 1 {
-					linkLabel = strings.Join(parts[1:], " ")
-				}
-
-				link := Link{
-					Index: linkIndex,
-					URL:   linkURL,
-					Text:  linkLabel,
-					Line:  len(s.content),
-				}
-				s.links = append(s.links, link)
-
-				// Display link with index
-				line = fmt.Sprintf("[%d] %s", linkIndex, linkLabel)
-				linkIndex++
+		text := s.encodeText(line.Text)
+
+		switch line.Type {
+		case gemtext.Link:
+			link := Link{
+				Index: linkIndex,
+				URL:   line.URL,
+				Text:  text,
+				Line:  len(s.content),
 			}
+			s.links = append(s.links, link)
+
+			// Display link with index
+			text = fmt.Sprintf("[%d] %s", linkIndex, text)
+			linkIndex++
+
+		case gemtext.PreformatToggle:
+			// Fences are hidden; an opening fence's alt text is shown instead
+			if text == "" {
+				continue
+			}
+			text = "[" + text + "]"
 		}
 
-		s.content = append(s.content, line)
+		s.content = append(s.content, ContentLine{
+			Type:  line.Type,
+			Level: line.Level,
+			Text:  text,
+		})
 	}
 }
 
+// markTruncated adds a note to the end of a page that was cut off at the
+// response size limit
+func (s *Session) markTruncated() {
+	note := fmt.Sprintf("[Response truncated at %d KB]", s.client.MaxBodySize/1024)
+	s.content = append(s.content,
+		ContentLine{Type: gemtext.Text},
+		ContentLine{Type: gemtext.Text, Text: note},
+	)
+	s.invalidateLayout()
+}
+
 func (s *Session) navigateBack() {
 	if s.historyIndex <= 0 {
 		return // Can't go back further
@@ -153,7 +234,9 @@ func (s *Session) navigateBack() {
 
 	// Move back in history
 	s.historyIndex--
-	s.loadFromHistory()
+	if !s.loadFromHistory() {
+		s.historyIndex++
+	}
 }
 
 func (s *Session) navigateForward() {
@@ -172,40 +255,45 @@ func (s *Session) navigateForward() {
 
 	// Move forward in history
 	s.historyIndex++
-	s.loadFromHistory()
+	if !s.loadFromHistory() {
+		s.historyIndex--
+	}
 }
 
-func (s *Session) loadFromHistory() {
+// loadFromHistory loads the current history entry, returning false if
+// the page couldn't be loaded
+func (s *Session) loadFromHistory() bool {
 	if s.historyIndex < 0 || s.historyIndex >= len(s.history) {
-		return
+		return false
 	}
 
 	entry := s.history[s.historyIndex]
 
-	s.write([]byte(fmt.Sprintf("\r\n\x1b[KLoading %s...\r\n", entry.URL)))
+	s.writeMessageLine(fmt.Sprintf("Loading %s... (ESC to cancel)\r\n", entry.URL))
 
-	resp, err := gemini.Fetch(entry.URL)
+	resp, err := s.fetch(entry.URL)
+	if errors.Is(err, errCancelled) {
+		return false
+	}
 	if err != nil {
 		s.write([]byte(fmt.Sprintf("Error: %v\r\n", err)))
 		s.write([]byte("Press any key to continue..."))
-		buf := make([]byte, 1)
-		s.conn.Read(buf)
+		s.readKey()
 		s.render()
-		return
+		return false
 	}
 
 	if resp.StatusCode < 20 || resp.StatusCode >= 30 {
 		s.write([]byte(fmt.Sprintf("Error: Status %d - %s\r\n", resp.StatusCode, resp.Meta)))
 		s.write([]byte("Press any key to continue..."))
-		buf := make([]byte, 1)
-		s.conn.Read(buf)
+		s.readKey()
 		s.render()
-		return
+		return false
 	}
 
 	// Load content and restore state
 	s.currentURL = entry.URL
-	s.parseContent(resp.Body)
+	s.showBody(resp)
 	s.scrollOffset = entry.ScrollOffset
 	s.selectedLink = entry.SelectedLink
 
@@ -217,4 +305,6 @@ func (s *Session) loadFromHistory() {
 	if s.scrollOffset >= totalDisplayLines {
 		s.scrollOffset = 0
 	}
+
+	return true
 }
@@ -3,11 +3,18 @@ package session
 import (
 	"fmt"
 	"strings"
+
+	"gemnet/internal/gemtext"
 )
 
 func (s *Session) render() {
+	if s.term.Teletype {
+		s.renderTeletype()
+		return
+	}
+
 	// Clear screen
-	s.write([]byte("\x1b[2J\x1b[H"))
+	s.write([]byte(s.term.ClearScreen))
 
 	// Status line
 	statusLine := "gemnet"
@@ -38,7 +45,7 @@ func (s *Session) render() {
 		progress := fmt.Sprintf("[%d/%d] %3d%%", currentLine, totalDisplayLines, percentage)
 
 		// Calculate how much space we have for the URL
-		maxURLLen := s.terminalWidth - len(progress) - 1 // -1 for space between URL and progress
+		maxURLLen := s.terminalWidth - 1 - len(progress) - 1 // -1 for space between URL and progress
 		if maxURLLen < 10 {
 			maxURLLen = 10
 		}
@@ -48,8 +55,9 @@ func (s *Session) render() {
 			url = url[:maxURLLen]
 		}
 
-		// Right-align progress by padding with spaces
-		padding := s.terminalWidth - len(url) - len(progress)
+		// Right-align progress by padding with spaces, stopping short of the
+		// last column so terminals that wrap there don't skip a line
+		padding := s.terminalWidth - 1 - len(url) - len(progress)
 		if padding < 1 {
 			padding = 1
 		}
@@ -59,7 +67,7 @@ func (s *Session) render() {
 
 	s.write([]byte(statusLine))
 	s.write([]byte("\r\n"))
-	s.write([]byte(strings.Repeat("-", s.terminalWidth)))
+	s.write([]byte(strings.Repeat("-", s.terminalWidth-1)))
 	s.write([]byte("\r\n"))
 
 	// Content area
@@ -76,15 +84,16 @@ func (s *Session) render() {
 		selectedContentLine = s.links[s.selectedLink].Line
 	}
 
-	// Render content with wrapping, accounting for scroll offset in display lines
-	currentDisplayLine := 0
+	// Render content with wrapping, starting from the content line at the
+	// scroll offset
+	firstContentLine := s.displayLineToContentLine(s.scrollOffset)
+	currentDisplayLine := s.contentLineToDisplayLine(firstContentLine)
 	linesDisplayed := 0
 
-	for contentLineIdx := 0; contentLineIdx < len(s.content) && linesDisplayed < visibleLines; contentLineIdx++ {
-		line := s.content[contentLineIdx]
-		wrappedLines := s.wrapLine(line)
+	for contentLineIdx := firstContentLine; contentLineIdx < len(s.content) && linesDisplayed < visibleLines; contentLineIdx++ {
+		wrappedLines := s.displaySegments(contentLineIdx)
 		isSelected := contentLineIdx == selectedContentLine
-		isHeader := s.headerLines[contentLineIdx]
+		isHeader := s.content[contentLineIdx].Type == gemtext.Heading
 
 		for _, wrappedLine := range wrappedLines {
 			// Skip lines before scroll offset
@@ -98,18 +107,7 @@ func (s *Session) render() {
 				break
 			}
 
-			if isSelected {
-				s.write([]byte("\x1b[7m")) // Reverse video
-			} else if isHeader {
-				s.write([]byte("\x1b[1m")) // Bold for headers
-			}
-
-			s.write([]byte(wrappedLine))
-
-			if isSelected || isHeader {
-				s.write([]byte("\x1b[0m")) // Reset
-			}
-
+			s.writeStyledLine(wrappedLine, isSelected, isHeader)
 			s.write([]byte("\r\n"))
 			linesDisplayed++
 			currentDisplayLine++
@@ -127,6 +125,12 @@ func (s *Session) renderPartialLinkUpdate() {
 		return
 	}
 
+	// Without cursor addressing the whole page has to be redrawn
+	if !s.term.CanAddress() {
+		s.render()
+		return
+	}
+
 	visibleLines := s.terminalHeight - 3
 
 	// Redraw old selected link (remove highlight)
@@ -164,11 +168,10 @@ func (s *Session) renderContentLine(contentLineIdx int, selectedLinkIdx int, vis
 	// +3 for status line, separator, and 0-indexing -> 1-indexing
 	absoluteRow := screenRow + 3
 
-	line := s.content[contentLineIdx]
-	wrappedLines := s.wrapLine(line)
+	wrappedLines := s.displaySegments(contentLineIdx)
 	isSelected := s.selectedLink >= 0 && s.selectedLink < len(s.links) &&
 	              s.links[s.selectedLink].Line == contentLineIdx
-	isHeader := s.headerLines[contentLineIdx]
+	isHeader := s.content[contentLineIdx].Type == gemtext.Heading
 
 	// Render each wrapped segment
 	for i, wrappedLine := range wrappedLines {
@@ -180,22 +183,65 @@ func (s *Session) renderContentLine(contentLineIdx int, selectedLinkIdx int, vis
 		}
 
 		// Move cursor to the line position
-		s.write([]byte(fmt.Sprintf("\x1b[%d;1H", currentRow)))
+		s.write([]byte(s.term.MoveTo(currentRow, 1)))
 
 		// Clear the line
-		s.write([]byte("\x1b[K"))
+		s.eraseLine()
 
-		// Apply formatting
-		if isSelected {
-			s.write([]byte("\x1b[7m")) // Reverse video
-		} else if isHeader {
-			s.write([]byte("\x1b[1m")) // Bold for headers
+		s.writeStyledLine(wrappedLine, isSelected, isHeader)
+	}
+}
+
+// writeStyledLine writes one display line, highlighting it if it belongs to
+// the selected link or a header
+func (s *Session) writeStyledLine(line string, isSelected, isHeader bool) {
+	switch {
+	case isSelected && s.term.HasReverse():
+		s.write([]byte(s.term.ReverseVideo(line)))
+	case isSelected:
+		// No reverse video, so mark the link number as "<n>" instead of "[n]"
+		if strings.HasPrefix(line, "[") {
+			if end := strings.Index(line, "]"); end > 0 {
+				line = "<" + line[1:end] + ">" + line[end+1:]
+			}
 		}
+		s.write([]byte(line))
+	case isHeader && s.term.Bold != "":
+		s.write([]byte(s.term.Bold + line + s.term.Reset))
+	default:
+		s.write([]byte(line))
+	}
+}
 
-		s.write([]byte(wrappedLine))
+// eraseLine clears the current line from the cursor. Addressable terminals
+// without an erase sequence get the line overwritten with spaces instead.
+func (s *Session) eraseLine() {
+	if s.term.EraseLine != "" {
+		s.write([]byte(s.term.EraseLine))
+		return
+	}
+	if s.term.CanAddress() {
+		// Overwrite with spaces and back up, since some terminals
+		// treat CR as a full newline
+		s.write([]byte(strings.Repeat(" ", s.terminalWidth-1)))
+		s.write([]byte(strings.Repeat("\b", s.terminalWidth-1)))
+	}
+}
 
-		if isSelected || isHeader {
-			s.write([]byte("\x1b[0m")) // Reset
-		}
+// returnToLineStart moves the cursor back to the start of the line it is
+// on, given how many characters have been written there
+func (s *Session) returnToLineStart(written int) {
+	if s.term.ReturnIsNewline {
+		s.write([]byte(strings.Repeat("\b", written)))
+		return
 	}
+	s.write([]byte("\r"))
+}
+
+// writeMessageLine starts a fresh line below the cursor for a prompt or
+// status message
+func (s *Session) writeMessageLine(text string) {
+	s.write([]byte("\r\n"))
+	s.eraseLine()
+	s.write([]byte(text))
 }
@@ -1,5 +1,12 @@
 package session
 
+import (
+	"sort"
+	"unicode/utf8"
+
+	"gemnet/internal/gemtext"
+)
+
 func (s *Session) handleArrowKey(delta int) {
 	visibleLines := s.terminalHeight - 3
 
@@ -18,8 +25,7 @@ func (s *Session) handleArrowKey(delta int) {
 	}
 
 	// Check if next link is visible on current screen
-	nextLinkContentLine := s.links[nextLinkIdx].Line
-	nextLinkDisplayLine := s.contentLineToDisplayLine(nextLinkContentLine)
+	nextLinkDisplayLine := s.linkDisplayLine(nextLinkIdx)
 
 	isVisible := nextLinkDisplayLine >= s.scrollOffset &&
 	             nextLinkDisplayLine < s.scrollOffset+visibleLines
@@ -31,7 +37,7 @@ func (s *Session) handleArrowKey(delta int) {
 		// Link is off-screen, page scroll and then select it
 		s.scrollPageWithDirection(delta)
 		// After page scroll, try to select the next link if it's now visible
-		nextLinkDisplayLine = s.contentLineToDisplayLine(nextLinkContentLine)
+		nextLinkDisplayLine = s.linkDisplayLine(nextLinkIdx)
 		isNowVisible := nextLinkDisplayLine >= s.scrollOffset &&
 		                nextLinkDisplayLine < s.scrollOffset+visibleLines
 		if isNowVisible {
@@ -66,8 +72,7 @@ func (s *Session) updateLinkSelectionWithDirection(delta int) {
 
 	// Check if current selection is visible
 	if s.selectedLink >= 0 && s.selectedLink < len(s.links) {
-		currentLinkContentLine := s.links[s.selectedLink].Line
-		currentLinkDisplayLine := s.contentLineToDisplayLine(currentLinkContentLine)
+		currentLinkDisplayLine := s.linkDisplayLine(s.selectedLink)
 
 		isVisible := currentLinkDisplayLine >= s.scrollOffset &&
 		             currentLinkDisplayLine < s.scrollOffset+visibleLines
@@ -77,24 +82,22 @@ func (s *Session) updateLinkSelectionWithDirection(delta int) {
 		}
 	}
 
+	// Links are in page order, so their display lines are sorted
+	linkLines := s.getLayout().linkLines
+
 	if delta < 0 {
 		// Scrolling up - find LAST visible link
-		for i := len(s.links) - 1; i >= 0; i-- {
-			link := s.links[i]
-			linkDisplayLine := s.contentLineToDisplayLine(link.Line)
-			if linkDisplayLine >= s.scrollOffset && linkDisplayLine < s.scrollOffset+visibleLines {
-				s.selectedLink = i
-				return
-			}
+		i := sort.SearchInts(linkLines, s.scrollOffset+visibleLines) - 1
+		if i >= 0 && linkLines[i] >= s.scrollOffset {
+			s.selectedLink = i
+			return
 		}
 	} else {
 		// Scrolling down - find FIRST visible link
-		for i, link := range s.links {
-			linkDisplayLine := s.contentLineToDisplayLine(link.Line)
-			if linkDisplayLine >= s.scrollOffset && linkDisplayLine < s.scrollOffset+visibleLines {
-				s.selectedLink = i
-				return
-			}
+		i := sort.SearchInts(linkLines, s.scrollOffset)
+		if i < len(linkLines) && linkLines[i] < s.scrollOffset+visibleLines {
+			s.selectedLink = i
+			return
 		}
 	}
 
@@ -116,8 +119,7 @@ func (s *Session) moveLinkSelection(delta int) {
 	}
 
 	// Auto-scroll to keep selected link visible (in display lines)
-	linkContentLine := s.links[s.selectedLink].Line
-	linkDisplayLine := s.contentLineToDisplayLine(linkContentLine)
+	linkDisplayLine := s.linkDisplayLine(s.selectedLink)
 	visibleLines := s.terminalHeight - 3
 
 	if linkDisplayLine < s.scrollOffset {
@@ -191,45 +193,35 @@ func (s *Session) scrollPageWithDirection(delta int) {
 	s.updateLinkSelectionWithDirection(delta)
 }
 
-func (s *Session) wrapLine(line string) []string {
-	if len(line) <= s.terminalWidth-1 {
-		return []string{line}
+// scrollHorizontal shifts preformatted lines left or right by half a screen
+func (s *Session) scrollHorizontal(delta int) {
+	step := (s.terminalWidth - 1) / 2
+	newHScroll := s.hScroll + delta*step
+	if newHScroll < 0 {
+		newHScroll = 0
 	}
 
-	var wrapped []string
-	for len(line) > 0 {
-		if len(line) <= s.terminalWidth-1 {
-			wrapped = append(wrapped, line)
-			break
+	// Don't scroll past the end of the widest preformatted line
+	widest := 0
+	for _, line := range s.content {
+		if line.Type != gemtext.Preformatted {
+			continue
+		}
+		if n := utf8.RuneCountInString(line.Text); n > widest {
+			widest = n
 		}
-		wrapped = append(wrapped, line[:s.terminalWidth-1])
-		line = line[s.terminalWidth-1:]
 	}
-	return wrapped
-}
-
-// getDisplayLineCount returns how many display lines a content line takes
-func (s *Session) getDisplayLineCount(contentLineIdx int) int {
-	if contentLineIdx < 0 || contentLineIdx >= len(s.content) {
-		return 0
+	maxHScroll := widest - (s.terminalWidth - 1)
+	if maxHScroll < 0 {
+		maxHScroll = 0
 	}
-	return len(s.wrapLine(s.content[contentLineIdx]))
-}
-
-// contentLineToDisplayLine converts a content line index to its first display line index
-func (s *Session) contentLineToDisplayLine(contentLineIdx int) int {
-	displayLine := 0
-	for i := 0; i < contentLineIdx && i < len(s.content); i++ {
-		displayLine += s.getDisplayLineCount(i)
+	if newHScroll > maxHScroll {
+		newHScroll = maxHScroll
 	}
-	return displayLine
-}
 
-// getTotalDisplayLines returns the total number of display lines
-func (s *Session) getTotalDisplayLines() int {
-	total := 0
-	for i := 0; i < len(s.content); i++ {
-		total += s.getDisplayLineCount(i)
+	if newHScroll == s.hScroll {
+		s.write([]byte("\x07")) // BEL - beep
+		return
 	}
-	return total
+	s.hScroll = newHScroll
 }
@@ -1,7 +1,17 @@
 package session
 
 import (
-	"net"
+	"bytes"
+	"errors"
+	"time"
+
+	"gemnet/internal/charset"
+	"gemnet/internal/config"
+	"gemnet/internal/gemini"
+	"gemnet/internal/gemtext"
+	"gemnet/internal/keymap"
+	"gemnet/internal/telnet"
+	"gemnet/internal/terminal"
 )
 
 type Link struct {
@@ -11,6 +21,13 @@ type Link struct {
 	Line  int // Line number where link appears
 }
 
+// ContentLine is one line of the current page, ready for display
+type ContentLine struct {
+	Type  gemtext.LineType
+	Level int    // Heading level 1-3
+	Text  string // Converted for the terminal; links include their "[n] " prefix
+}
+
 type HistoryEntry struct {
 	URL          string
 	ScrollOffset int
@@ -18,29 +35,50 @@ type HistoryEntry struct {
 }
 
 type Session struct {
-	conn             net.Conn
+	cfg              *config.Config
+	conn             *telnet.Conn
+	client           *gemini.Client
+	identities       []*gemini.Identity // Identities unlocked in this session
+	failedOpens      int                // Wrong passphrases given for identities
+	term             *terminal.Profile  // How to drive the client's terminal
+	keys             *keymap.Keymap     // What each key does on the page view
 	currentURL       string
-	content          []string // Content lines
+	content          []ContentLine
 	links            []Link
-	headerLines      map[int]bool // Set of line numbers that are headers
+	hScroll          int     // Horizontal offset for preformatted lines
+	layout           *layout // Cached wrapping of content; see getLayout
 	selectedLink     int
-	scrollOffset     int  // Display line offset (accounts for wrapping)
-	prevSelectedLink int  // Previous selected link for partial redraw
-	prevScrollOffset int  // Previous scroll offset for partial redraw
-	lastByte         byte // Last byte received (for CRLF handling)
+	scrollOffset     int    // Display line offset (accounts for wrapping)
+	prevSelectedLink int    // Previous selected link for partial redraw
+	prevScrollOffset int    // Previous scroll offset for partial redraw
+	listed           int    // Display lines printed so far in teletype mode
+	lastByte         byte   // Last byte received (for CRLF handling)
+	input            []byte // Translated key bytes not yet read
 	history          []HistoryEntry
 	historyIndex     int // Current position in history (-1 means no history)
 	terminalHeight   int
 	terminalWidth    int
-	inputMode        string // "", "goto"
-	inputBuffer      string
+	lineEnding       string           // Sent in place of CR LF
+	charset          *charset.Charset // What the terminal can display
+	echo             bool             // Echo typed characters at prompts
+	idle             bool             // Waiting for a keystroke in the main loop
+	progressShown    bool             // A download progress line is on screen
+	progressWidth    int              // Characters written on the progress line
+	lastProgress     time.Time        // When the progress line was last updated
 }
 
-func New(conn net.Conn) *Session {
+func New(conn *telnet.Conn, cfg *config.Config, client *gemini.Client) *Session {
 	return &Session{
+		cfg:            cfg,
 		conn:           conn,
-		terminalHeight: 24,
-		terminalWidth:  80,
+		client:         client,
+		term:           terminal.ANSI,
+		keys:           keymapFor(cfg.Keymap),
+		terminalHeight: cfg.TerminalHeight,
+		terminalWidth:  cfg.TerminalWidth,
+		lineEnding:     "\r\n",
+		charset:        charset.ASCII,
+		echo:           true,
 		selectedLink:   0,
 		scrollOffset:   0,
 		history:        make([]HistoryEntry, 0),
@@ -49,30 +87,44 @@ func New(conn net.Conn) *Session {
 }
 
 func (s *Session) Run() error {
+	// Negotiate terminal options before drawing anything
+	s.negotiate()
+
+	if s.needsSetup() {
+		if err := s.runSetup(); err != nil {
+			return err
+		}
+	}
+
 	// Initialize terminal
-	s.write([]byte("\x1b[2J\x1b[H")) // Clear screen and move to home
-	s.write([]byte("Welcome to gemnet - Gemini over Telnet\r\n"))
+	s.write([]byte(s.term.ClearScreen)) // Clear screen and move to home
+	s.write([]byte(s.cfg.Banner + "\r\n"))
 	s.write([]byte("\r\n"))
 
 	// Load default page
-	s.navigateTo("gemini://geminiprotocol.net/")
+	s.navigateTo(s.cfg.StartURL)
 
 	// Main input loop
-	buf := make([]byte, 1)
 	for {
-		n, err := s.conn.Read(buf)
+		s.idle = true
+		b, err := s.readByte()
+		s.idle = false
 		if err != nil {
+			if errors.Is(err, errIdleTimeout) {
+				s.write([]byte("\r\nIdle timeout, disconnecting.\r\n"))
+			}
 			return err
 		}
 
-		if n > 0 {
-			if err := s.handleInput(buf[0]); err != nil {
-				return err
-			}
+		if err := s.handleInput(b); err != nil {
+			return err
 		}
 	}
 }
 
 func (s *Session) write(data []byte) {
-	s.conn.Write(data)
+	if s.lineEnding != "\r\n" {
+		data = bytes.ReplaceAll(data, []byte("\r\n"), []byte(s.lineEnding))
+	}
+	s.conn.Write(s.charset.Encode(data))
 }
@@ -0,0 +1,151 @@
+package session
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gemnet/internal/charset"
+	"gemnet/internal/config"
+	"gemnet/internal/telnet"
+	"gemnet/internal/terminal"
+)
+
+// Line ending styles offered by the setup screen
+var lineEndings = []struct {
+	name  string
+	value string
+}{
+	{"CR LF", "\r\n"},
+	{"LF", "\n"},
+	{"CR", "\r"},
+}
+
+// needsSetup reports whether the setup screen should be shown
+func (s *Session) needsSetup() bool {
+	switch s.cfg.Setup {
+	case config.SetupAlways:
+		return true
+	case config.SetupNever:
+		return false
+	}
+	// The client told us nothing about itself
+	return !s.conn.RemoteEnabled(telnet.OptNAWS) && len(s.conn.TerminalTypes()) == 0
+}
+
+// runSetup asks the user about their terminal. It avoids control
+// sequences entirely, since we don't yet know what the terminal can do.
+func (s *Session) runSetup() error {
+	s.write([]byte("\r\ngemnet terminal setup\r\n\r\n"))
+	s.write([]byte("Press Enter to set up your terminal, or any other key to skip.\r\n"))
+
+	b, err := s.readKey()
+	if err != nil {
+		return err
+	}
+	if b != '\r' && b != '\n' {
+		s.lastByte = b
+		return nil
+	}
+	s.lastByte = b
+
+	width, err := s.askNumber("Columns", s.terminalWidth, minTerminalWidth)
+	if err != nil {
+		return err
+	}
+	height, err := s.askNumber("Rows", s.terminalHeight, minTerminalHeight)
+	if err != nil {
+		return err
+	}
+
+	termNames := make([]string, len(terminal.Profiles))
+	termDefault := 0
+	for i, p := range terminal.Profiles {
+		termNames[i] = p.Description
+		if p == s.term {
+			termDefault = i
+		}
+	}
+	termChoice, err := s.askChoice("Terminal type", termNames, termDefault)
+	if err != nil {
+		return err
+	}
+
+	profile := terminal.Profiles[termChoice]
+
+	// Some terminals only work one way, so there's nothing to ask
+	lineEnding := s.lineEnding
+	if profile.Newline == "" {
+		endingNames := make([]string, len(lineEndings))
+		endingDefault := 0
+		for i, e := range lineEndings {
+			endingNames[i] = e.name
+			if e.value == s.lineEnding {
+				endingDefault = i
+			}
+		}
+		endingChoice, err := s.askChoice("Line ending", endingNames, endingDefault)
+		if err != nil {
+			return err
+		}
+		lineEnding = lineEndings[endingChoice].value
+	}
+
+	cs := s.charset
+	if profile.Charset == nil {
+		charsetNames := make([]string, len(charset.Charsets))
+		charsetDefault := 0
+		for i, c := range charset.Charsets {
+			charsetNames[i] = c.Description
+			if c == s.charset {
+				charsetDefault = i
+			}
+		}
+		charsetChoice, err := s.askChoice("Character set", charsetNames, charsetDefault)
+		if err != nil {
+			return err
+		}
+		cs = charset.Charsets[charsetChoice]
+	}
+
+	s.terminalWidth = width
+	s.terminalHeight = height
+	s.lineEnding = lineEnding
+	s.charset = cs
+	s.useProfile(profile)
+	return nil
+}
+
+// askNumber prompts for a number, keeping the current value on an empty
+// or invalid answer
+func (s *Session) askNumber(label string, current, min int) (int, error) {
+	s.write([]byte(fmt.Sprintf("\r\n%s [%d]: ", label, current)))
+	answer, _, err := s.readLine(false)
+	if err != nil {
+		return current, err
+	}
+	n, convErr := strconv.Atoi(strings.TrimSpace(answer))
+	if convErr != nil || n < min || n > 255 {
+		return current, nil
+	}
+	return n, nil
+}
+
+// askChoice shows a numbered list and returns the index picked, keeping
+// the current choice on an empty or invalid answer
+func (s *Session) askChoice(label string, options []string, current int) (int, error) {
+	s.write([]byte(fmt.Sprintf("\r\n%s:\r\n", label)))
+	for i, option := range options {
+		s.write([]byte(fmt.Sprintf("  %d) %s\r\n", i+1, option)))
+	}
+	s.write([]byte(fmt.Sprintf("Choice [%d]: ", current+1)))
+	answer, _, err := s.readLine(false)
+	if err != nil {
+		return current, err
+	}
+	n, convErr := strconv.Atoi(strings.TrimSpace(answer))
+	if convErr != nil || n < 1 || n > len(options) {
+		return current, nil
+	}
+	return n - 1, nil
+}
@@ -0,0 +1,196 @@
+package session
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gemnet/internal/gemtext"
+)
+
+// morePrompt pauses a teletype listing after each screenful
+const morePrompt = "--More--"
+
+// renderTeletype prints a newly loaded page, then the command prompt.
+// Teletypes can't redraw, so a page is only printed once unless the user
+// asks for more of it or for it again.
+func (s *Session) renderTeletype() {
+	if s.content == nil {
+		s.write([]byte("No page loaded. Press 'g' to enter a URL.\r\n"))
+		return
+	}
+
+	if s.listed == 0 {
+		s.write([]byte("\r\n" + s.currentURL + "\r\n"))
+		s.write([]byte(strings.Repeat("-", s.terminalWidth-1) + "\r\n"))
+		s.listPage()
+	}
+
+	prompt := "Link number, B back, F forward, G go to URL, L links, Q quit: "
+	if s.listed < s.getTotalDisplayLines() {
+		prompt = "Enter for more, " + prompt
+	}
+	s.write([]byte("\r\n" + prompt))
+}
+
+// listPage prints display lines from where the listing left off, pausing
+// with --More-- whenever the screen is full
+func (s *Session) listPage() {
+	for shown := 0; s.listed < s.getTotalDisplayLines(); shown++ {
+		pageLines := s.terminalHeight - 1
+		if shown >= pageLines {
+			// The terminal may be resized while waiting, which changes
+			// the page length and how the lines wrap
+			more := s.askMore()
+			if more == 0 || s.listed >= s.getTotalDisplayLines() {
+				return
+			}
+			shown = s.terminalHeight - 1 - more
+		}
+
+		contentLineIdx := s.displayLineToContentLine(s.listed)
+		segment := s.listed - s.contentLineToDisplayLine(contentLineIdx)
+		line := s.displaySegments(contentLineIdx)[segment]
+		isHeader := s.content[contentLineIdx].Type == gemtext.Heading
+
+		s.writeStyledLine(line, false, isHeader)
+		s.write([]byte("\r\n"))
+		s.listed++
+	}
+}
+
+// askMore shows the --More-- prompt and returns how many more lines to
+// print: a screenful for space, one for Enter, or none to stop. A digit
+// stops the listing and is kept for the link number prompt.
+func (s *Session) askMore() int {
+	s.write([]byte(morePrompt))
+	defer s.write([]byte("\r" + strings.Repeat(" ", len(morePrompt)) + "\r"))
+
+	for {
+		b, err := s.readKey()
+		if err != nil {
+			return 0
+		}
+
+		switch {
+		case b == '\n' && s.lastByte == '\r':
+			s.lastByte = b
+			continue // Second half of CR LF
+		case b == ' ':
+			s.lastByte = b
+			return s.terminalHeight - 1
+		case b == '\r', b == '\n':
+			s.lastByte = b
+			return 1
+		case b >= '0' && b <= '9':
+			s.unreadByte(b)
+		}
+		s.lastByte = b
+		return 0
+	}
+}
+
+// handleTeletypeInput handles a key at the teletype command prompt
+func (s *Session) handleTeletypeInput(b byte) error {
+	switch {
+	case b == '\n' && s.lastByte == '\r':
+		// Second half of CR LF
+
+	case b >= '0' && b <= '9':
+		// Let the line editor echo the digit and read the rest
+		s.unreadByte(b)
+		return s.followLinkNumber()
+
+	case b == '\r', b == '\n', b == ' ':
+		// Continue the listing, or just ask again if it's finished
+		s.write([]byte("\r\n"))
+		s.listPage()
+		s.render()
+
+	case b == 'b', b == 'B', b == 0x7f, b == 0x08:
+		s.navigateBack()
+		s.render()
+
+	case b == 'f', b == 'F':
+		s.navigateForward()
+		s.render()
+
+	case b == 'g', b == 'G':
+		s.lastByte = b
+		return s.promptGoto()
+
+	case b == 'l', b == 'L':
+		s.listLinks()
+		s.render()
+
+	case b == 'r', b == 'R':
+		// Print the page again from the top
+		s.listed = 0
+		s.render()
+
+	case b == 'q', b == 'Q':
+		return fmt.Errorf("user quit")
+
+	case b == 0x1b:
+		// Arrow keys still move through history
+		k, err := s.readEscapeKey()
+		if err != nil {
+			return err
+		}
+		switch k {
+		case keyLeft:
+			s.navigateBack()
+			s.render()
+		case keyRight:
+			s.navigateForward()
+			s.render()
+		}
+	}
+	s.lastByte = b
+	return nil
+}
+
+// followLinkNumber reads a link number at the prompt and follows it
+func (s *Session) followLinkNumber() error {
+	input, ok, err := s.readLine(false)
+	if err != nil {
+		return err
+	}
+	input = strings.TrimSpace(input)
+	if !ok || input == "" {
+		s.write([]byte("\r\n"))
+		s.render()
+		return nil
+	}
+
+	n, err := strconv.Atoi(input)
+	if err != nil || n < 0 || n >= len(s.links) {
+		s.write([]byte(fmt.Sprintf("\r\nNo link %s on this page.", input)))
+		s.render()
+		return nil
+	}
+
+	s.selectedLink = n
+	s.navigateTo(s.links[n].URL)
+	return nil
+}
+
+// listLinks prints every link on the page with its number and URL
+func (s *Session) listLinks() {
+	s.write([]byte("\r\n"))
+	if len(s.links) == 0 {
+		s.write([]byte("This page has no links.\r\n"))
+		return
+	}
+	pageLines := s.terminalHeight - 1
+	for i, shown := 0, 0; i < len(s.links); i, shown = i+1, shown+1 {
+		if shown == pageLines {
+			more := s.askMore()
+			if more == 0 {
+				return
+			}
+			shown = pageLines - more
+		}
+		s.write([]byte(s.encodeText(fmt.Sprintf("[%d] %s", i, s.links[i].URL)) + "\r\n"))
+	}
+}
@@ -0,0 +1,141 @@
+package session
+
+import (
+	"time"
+
+	"gemnet/internal/charset"
+	"gemnet/internal/telnet"
+	"gemnet/internal/terminal"
+)
+
+// negotiationTimeout is how long to wait for the client to answer our
+// option requests at connect time
+const negotiationTimeout = 2 * time.Second
+
+// charsetOffer lists the charsets offered with telnet CHARSET, best first
+var charsetOffer = []*charset.Charset{
+	charset.UTF8, charset.CP437, charset.CP850, charset.Latin1, charset.MacRoman, charset.ASCII,
+}
+
+// Smallest window we can lay out: room for the status line, separator,
+// one content line and the URL
+const (
+	minTerminalWidth  = 20
+	minTerminalHeight = 4
+)
+
+// negotiate asks the client for its terminal details and applies them
+func (s *Session) negotiate() {
+	s.conn.OnWindowSize = s.handleResize
+	s.conn.Do(telnet.OptNAWS)
+	s.conn.Do(telnet.OptTerminalType)
+
+	names := make([]string, len(charsetOffer))
+	for i, c := range charsetOffer {
+		names[i] = c.Name
+	}
+	s.conn.OfferCharsets(names)
+
+	// Character-at-a-time mode with the server doing the echoing
+	s.conn.Will(telnet.OptEcho)
+	s.conn.Will(telnet.OptSuppressGoAhead)
+	s.conn.Do(telnet.OptSuppressGoAhead)
+
+	s.conn.Negotiate(negotiationTimeout)
+
+	// Echo ourselves unless the client insisted on echoing locally.
+	// Clients that ignore telnet options entirely get server echo, as
+	// most of them don't echo on their own.
+	s.echo = s.conn.LocalEnabled(telnet.OptEcho) || !s.conn.LocalAnswered(telnet.OptEcho)
+
+	// Use the first terminal type we have a profile for
+	for _, name := range s.conn.TerminalTypes() {
+		if profile := terminal.ForTerminalType(name); profile != nil {
+			s.useProfile(profile)
+			break
+		}
+	}
+	if w, h := s.conn.WindowSize(); (w == 0 || h == 0) && s.term.Width > 0 {
+		// Clients that don't report their size still have a screen of
+		// a known size if they are, say, a Commodore 64
+		s.handleResize(s.term.Width, s.term.Height)
+	}
+	if s.term.Charset != nil {
+		return
+	}
+
+	// Use the charset the client picked, or else guess from its
+	// terminal type
+	if c := charset.Lookup(s.conn.Charset()); c != nil {
+		s.charset = c
+		return
+	}
+	for _, name := range s.conn.TerminalTypes() {
+		if c := charset.ForTerminalType(name); c != nil {
+			s.charset = c
+			break
+		}
+	}
+}
+
+// useProfile switches to a terminal profile, along with the charset and
+// line ending it requires
+func (s *Session) useProfile(profile *terminal.Profile) {
+	s.term = profile
+	if profile.Charset != nil {
+		s.charset = profile.Charset
+	}
+	if profile.Newline != "" {
+		s.lineEnding = profile.Newline
+	}
+}
+
+// handleResize applies a new window size reported by the client,
+// keeping the top visible content line at the top of the screen
+func (s *Session) handleResize(width, height int) {
+	if width == 0 || height == 0 {
+		return // Client doesn't know its size
+	}
+	if width < minTerminalWidth {
+		width = minTerminalWidth
+	}
+	if height < minTerminalHeight {
+		height = minTerminalHeight
+	}
+	if width == s.terminalWidth && height == s.terminalHeight {
+		return
+	}
+
+	// Remember which content lines are at the top and where a teletype
+	// listing has got to before re-wrapping
+	topContentLine := s.displayLineToContentLine(s.scrollOffset)
+	listedContentLine := len(s.content)
+	if s.listed < s.getTotalDisplayLines() {
+		listedContentLine = s.displayLineToContentLine(s.listed)
+	}
+
+	s.terminalWidth = width
+	s.terminalHeight = height
+
+	if s.content == nil {
+		return
+	}
+
+	s.scrollOffset = s.contentLineToDisplayLine(topContentLine)
+	if s.listed > 0 {
+		s.listed = s.contentLineToDisplayLine(listedContentLine)
+	}
+	maxScroll := s.getTotalDisplayLines() - (s.terminalHeight - 3)
+	if maxScroll < 0 {
+		maxScroll = 0
+	}
+	if s.scrollOffset > maxScroll {
+		s.scrollOffset = maxScroll
+	}
+	s.updateLinkSelection()
+
+	// Only redraw when we're not in the middle of a prompt or message
+	if s.idle {
+		s.render()
+	}
+}
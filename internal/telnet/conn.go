@@ -0,0 +1,460 @@
+package telnet
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// Parser states for the incoming byte stream
+const (
+	stateData = iota
+	stateIAC
+	stateOption
+	stateSB
+	stateSBIAC
+	stateCR
+)
+
+// maxSubnegotiation bounds the size of a single SB ... SE payload
+const maxSubnegotiation = 512
+
+// optionState tracks one option on one side of the connection
+type optionState struct {
+	enabled  bool
+	pending  bool // We sent a request and are waiting for the answer
+	answered bool // The client has said something about this option
+}
+
+// Conn wraps a net.Conn with the telnet protocol. Read returns only data
+// bytes, with IAC commands and option negotiation stripped out and
+// answered. Write escapes IAC bytes in outgoing data.
+type Conn struct {
+	net.Conn
+
+	// OnWindowSize is called from Read whenever the client reports its
+	// window size via NAWS
+	OnWindowSize func(width, height int)
+
+	writeMu sync.Mutex
+
+	// Options we are willing to enable on our side (answer DO with WILL)
+	supportedLocal map[byte]bool
+	// Options we are willing to let the client enable (answer WILL with DO)
+	supportedRemote map[byte]bool
+
+	local  map[byte]*optionState
+	remote map[byte]*optionState
+
+	// Subnegotiations we expect the client to send, used by Negotiate
+	awaiting map[byte]bool
+
+	width  int
+	height int
+
+	terminalTypes []string
+
+	charsets []string // Offered to the client with CHARSET
+	charset  string   // The one it accepted
+
+	state   int
+	command byte   // WILL/WONT/DO/DONT being parsed
+	sbData  []byte // Subnegotiation payload being collected
+	raw     []byte
+	pending []byte // Data bytes decoded but not yet returned by Read
+}
+
+// NewConn wraps conn with telnet protocol handling
+func NewConn(conn net.Conn) *Conn {
+	return &Conn{
+		Conn: conn,
+		supportedLocal: map[byte]bool{
+			OptSuppressGoAhead: true,
+		},
+		supportedRemote: map[byte]bool{
+			OptSuppressGoAhead: true,
+		},
+		local:    make(map[byte]*optionState),
+		remote:   make(map[byte]*optionState),
+		awaiting: make(map[byte]bool),
+		raw:      make([]byte, 1024),
+	}
+}
+
+// Read reads data bytes from the connection, processing any telnet
+// commands found along the way. It blocks until at least one data byte
+// is available or an error occurs.
+func (c *Conn) Read(p []byte) (int, error) {
+	for len(c.pending) == 0 {
+		n, err := c.Conn.Read(c.raw)
+		if n > 0 {
+			c.process(c.raw[:n])
+		}
+		if err != nil {
+			if len(c.pending) > 0 {
+				break
+			}
+			return 0, err
+		}
+	}
+
+	n := copy(p, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
+
+// Negotiate processes incoming telnet commands until every option request
+// we sent has been answered, or until timeout elapses. Data bytes that
+// arrive in the meantime are kept for the next Read.
+func (c *Conn) Negotiate(timeout time.Duration) error {
+	if err := c.Conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return err
+	}
+	defer c.Conn.SetReadDeadline(time.Time{})
+
+	for !c.settled() {
+		n, err := c.Conn.Read(c.raw)
+		if n > 0 {
+			c.process(c.raw[:n])
+		}
+		if err != nil {
+			if errors.Is(err, os.ErrDeadlineExceeded) {
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// settled reports whether no option requests or expected
+// subnegotiations are outstanding
+func (c *Conn) settled() bool {
+	for _, st := range c.local {
+		if st.pending {
+			return false
+		}
+	}
+	for _, st := range c.remote {
+		if st.pending {
+			return false
+		}
+	}
+	return len(c.awaiting) == 0
+}
+
+// WindowSize returns the last size reported via NAWS, or zeros if the
+// client hasn't reported one
+func (c *Conn) WindowSize() (width, height int) {
+	return c.width, c.height
+}
+
+// TerminalTypes returns the terminal type names reported via TERMINAL-TYPE,
+// in the order the client offered them
+func (c *Conn) TerminalTypes() []string {
+	return c.terminalTypes
+}
+
+// OfferCharsets offers the client a list of character sets by name,
+// most preferred first (RFC 2066). Charset reports the one it picks.
+func (c *Conn) OfferCharsets(names []string) error {
+	c.charsets = names
+	return c.Will(OptCharset)
+}
+
+// Charset returns the character set the client accepted from
+// OfferCharsets, or "" if it hasn't accepted one
+func (c *Conn) Charset() string {
+	return c.charset
+}
+
+// Write writes data to the connection, doubling any IAC bytes
+func (c *Conn) Write(p []byte) (int, error) {
+	data := p
+	if bytes.IndexByte(p, IAC) >= 0 {
+		data = bytes.ReplaceAll(p, []byte{IAC}, []byte{IAC, IAC})
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if _, err := c.Conn.Write(data); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Will offers to enable an option on our side
+func (c *Conn) Will(option byte) error {
+	c.supportedLocal[option] = true
+	st := c.optionState(c.local, option)
+	if st.enabled || st.pending {
+		return nil
+	}
+	st.pending = true
+	return c.sendCommand(WILL, option)
+}
+
+// Do asks the client to enable an option on its side
+func (c *Conn) Do(option byte) error {
+	c.supportedRemote[option] = true
+	st := c.optionState(c.remote, option)
+	if st.enabled || st.pending {
+		return nil
+	}
+	st.pending = true
+	return c.sendCommand(DO, option)
+}
+
+// LocalEnabled reports whether an option is enabled on our side
+func (c *Conn) LocalEnabled(option byte) bool {
+	return c.optionState(c.local, option).enabled
+}
+
+// LocalAnswered reports whether the client has sent DO or DONT for an
+// option on our side
+func (c *Conn) LocalAnswered(option byte) bool {
+	return c.optionState(c.local, option).answered
+}
+
+// RemoteEnabled reports whether the client has enabled an option
+func (c *Conn) RemoteEnabled(option byte) bool {
+	return c.optionState(c.remote, option).enabled
+}
+
+func (c *Conn) optionState(states map[byte]*optionState, option byte) *optionState {
+	st, ok := states[option]
+	if !ok {
+		st = &optionState{}
+		states[option] = st
+	}
+	return st
+}
+
+// process runs raw bytes from the network through the protocol parser
+func (c *Conn) process(data []byte) {
+	for _, b := range data {
+		switch c.state {
+		case stateData:
+			switch b {
+			case IAC:
+				c.state = stateIAC
+			case '\r':
+				c.pending = append(c.pending, b)
+				c.state = stateCR
+			default:
+				c.pending = append(c.pending, b)
+			}
+
+		case stateCR:
+			// NVT sends CR NUL for a bare carriage return
+			c.state = stateData
+			switch b {
+			case 0:
+			case IAC:
+				c.state = stateIAC
+			default:
+				c.pending = append(c.pending, b)
+			}
+
+		case stateIAC:
+			c.state = stateData
+			switch b {
+			case IAC: // Escaped 0xFF data byte
+				c.pending = append(c.pending, IAC)
+			case WILL, WONT, DO, DONT:
+				c.command = b
+				c.state = stateOption
+			case SB:
+				c.sbData = c.sbData[:0]
+				c.state = stateSB
+			case IP: // Interrupt process, sent by some clients for Ctrl-C
+				c.pending = append(c.pending, 0x03)
+			default:
+				// NOP, GA, AYT and friends carry no data for us
+			}
+
+		case stateOption:
+			c.state = stateData
+			c.handleNegotiation(c.command, b)
+
+		case stateSB:
+			if b == IAC {
+				c.state = stateSBIAC
+			} else if len(c.sbData) < maxSubnegotiation {
+				c.sbData = append(c.sbData, b)
+			}
+
+		case stateSBIAC:
+			switch b {
+			case SE:
+				c.state = stateData
+				if len(c.sbData) > 0 {
+					c.handleSubnegotiation(c.sbData[0], c.sbData[1:])
+				}
+			case IAC:
+				c.state = stateSB
+				if len(c.sbData) < maxSubnegotiation {
+					c.sbData = append(c.sbData, IAC)
+				}
+			default:
+				// Malformed subnegotiation; drop it and resume
+				c.state = stateData
+			}
+		}
+	}
+}
+
+// handleNegotiation answers a WILL/WONT/DO/DONT from the client,
+// agreeing to options we support and refusing the rest
+func (c *Conn) handleNegotiation(command, option byte) {
+	switch command {
+	case DO:
+		st := c.optionState(c.local, option)
+		st.answered = true
+		if !c.supportedLocal[option] {
+			st.pending = false
+			c.sendCommand(WONT, option)
+			return
+		}
+		if !st.enabled && !st.pending {
+			c.sendCommand(WILL, option)
+		}
+		if !st.enabled {
+			c.localEnabled(option)
+		}
+		st.enabled = true
+		st.pending = false
+
+	case DONT:
+		st := c.optionState(c.local, option)
+		st.answered = true
+		if st.enabled && !st.pending {
+			c.sendCommand(WONT, option)
+		}
+		st.enabled = false
+		st.pending = false
+
+	case WILL:
+		st := c.optionState(c.remote, option)
+		st.answered = true
+		if !c.supportedRemote[option] {
+			st.pending = false
+			c.sendCommand(DONT, option)
+			return
+		}
+		if !st.enabled && !st.pending {
+			c.sendCommand(DO, option)
+		}
+		if !st.enabled {
+			c.remoteEnabled(option)
+		}
+		st.enabled = true
+		st.pending = false
+
+	case WONT:
+		st := c.optionState(c.remote, option)
+		st.answered = true
+		if st.enabled && !st.pending {
+			c.sendCommand(DONT, option)
+		}
+		st.enabled = false
+		st.pending = false
+		delete(c.awaiting, option)
+	}
+}
+
+// localEnabled is called when the client agrees to one of our options
+func (c *Conn) localEnabled(option byte) {
+	switch option {
+	case OptCharset:
+		if len(c.charsets) == 0 {
+			return
+		}
+		c.awaiting[OptCharset] = true
+		request := []byte{IAC, SB, OptCharset, charsetRequest}
+		for _, name := range c.charsets {
+			request = append(request, ';')
+			request = append(request, name...)
+		}
+		c.writeRaw(append(request, IAC, SE))
+	}
+}
+
+// remoteEnabled is called when the client turns on one of its options
+func (c *Conn) remoteEnabled(option byte) {
+	switch option {
+	case OptNAWS:
+		// The client follows WILL NAWS with its window size
+		c.awaiting[OptNAWS] = true
+	case OptTerminalType:
+		c.awaiting[OptTerminalType] = true
+		c.requestTerminalType()
+	}
+}
+
+// requestTerminalType asks the client for its (next) terminal type
+func (c *Conn) requestTerminalType() error {
+	return c.writeRaw([]byte{IAC, SB, OptTerminalType, ttypeSend, IAC, SE})
+}
+
+// handleSubnegotiation processes a completed SB ... SE payload
+func (c *Conn) handleSubnegotiation(option byte, data []byte) {
+	delete(c.awaiting, option)
+
+	switch option {
+	case OptTerminalType:
+		if len(data) < 1 || data[0] != ttypeIs {
+			return
+		}
+		name := string(data[1:])
+		// Clients cycle through their supported types on each SEND and
+		// repeat the last one (or wrap around) when the list runs out
+		for _, seen := range c.terminalTypes {
+			if seen == name {
+				return
+			}
+		}
+		c.terminalTypes = append(c.terminalTypes, name)
+		if len(c.terminalTypes) < maxTerminalTypes {
+			c.awaiting[OptTerminalType] = true
+			c.requestTerminalType()
+		}
+
+	case OptCharset:
+		if len(data) < 1 {
+			return
+		}
+		switch data[0] {
+		case charsetAccepted:
+			c.charset = string(data[1:])
+		case charsetRequest:
+			// We make the offers; turn down the client's
+			c.writeRaw([]byte{IAC, SB, OptCharset, charsetRejected, IAC, SE})
+		}
+
+	case OptNAWS:
+		if len(data) < 4 {
+			return
+		}
+		c.width = int(data[0])<<8 | int(data[1])
+		c.height = int(data[2])<<8 | int(data[3])
+		if c.OnWindowSize != nil {
+			c.OnWindowSize(c.width, c.height)
+		}
+	}
+}
+
+func (c *Conn) sendCommand(command, option byte) error {
+	return c.writeRaw([]byte{IAC, command, option})
+}
+
+// writeRaw writes bytes to the connection without IAC escaping
+func (c *Conn) writeRaw(data []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_, err := c.Conn.Write(data)
+	return err
+}
@@ -0,0 +1,53 @@
+package telnet
+
+// Telnet command bytes (RFC 854)
+const (
+	SE   byte = 240 // End of subnegotiation
+	NOP  byte = 241 // No operation
+	DM   byte = 242 // Data mark
+	BRK  byte = 243 // Break
+	IP   byte = 244 // Interrupt process
+	AO   byte = 245 // Abort output
+	AYT  byte = 246 // Are you there
+	EC   byte = 247 // Erase character
+	EL   byte = 248 // Erase line
+	GA   byte = 249 // Go ahead
+	SB   byte = 250 // Start of subnegotiation
+	WILL byte = 251
+	WONT byte = 252
+	DO   byte = 253
+	DONT byte = 254
+	IAC  byte = 255 // Interpret as command
+)
+
+// Telnet option codes
+const (
+	OptBinary          byte = 0  // RFC 856
+	OptEcho            byte = 1  // RFC 857
+	OptSuppressGoAhead byte = 3  // RFC 858
+	OptStatus          byte = 5  // RFC 859
+	OptTimingMark      byte = 6  // RFC 860
+	OptTerminalType    byte = 24 // RFC 1091
+	OptNAWS            byte = 31 // RFC 1073
+	OptTerminalSpeed   byte = 32 // RFC 1079
+	OptLinemode        byte = 34 // RFC 1184
+	OptNewEnviron      byte = 39 // RFC 1572
+	OptCharset         byte = 42 // RFC 2066
+)
+
+// TERMINAL-TYPE subnegotiation commands (RFC 1091)
+const (
+	ttypeIs   byte = 0
+	ttypeSend byte = 1
+)
+
+// CHARSET subnegotiation commands (RFC 2066)
+const (
+	charsetRequest  byte = 1
+	charsetAccepted byte = 2
+	charsetRejected byte = 3
+)
+
+// maxTerminalTypes limits how many names we collect from a client that
+// cycles through terminal types
+const maxTerminalTypes = 8
@@ -0,0 +1,323 @@
+package telnet
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// fakeConn is a net.Conn that returns scripted reads, one chunk per call,
+// and records everything written to it
+type fakeConn struct {
+	net.Conn
+	reads   [][]byte
+	written bytes.Buffer
+}
+
+func (f *fakeConn) Read(p []byte) (int, error) {
+	if len(f.reads) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, f.reads[0])
+	if n < len(f.reads[0]) {
+		f.reads[0] = f.reads[0][n:]
+	} else {
+		f.reads = f.reads[1:]
+	}
+	return n, nil
+}
+
+func (f *fakeConn) Write(p []byte) (int, error) {
+	return f.written.Write(p)
+}
+
+func (f *fakeConn) SetReadDeadline(time.Time) error {
+	return nil
+}
+
+// cat joins byte strings, for writing telnet sequences readably
+func cat(parts ...interface{}) []byte {
+	var b []byte
+	for _, p := range parts {
+		switch p := p.(type) {
+		case byte:
+			b = append(b, p)
+		case string:
+			b = append(b, p...)
+		case []byte:
+			b = append(b, p...)
+		}
+	}
+	return b
+}
+
+func TestRead(t *testing.T) {
+	tests := []struct {
+		name      string
+		setup     func(c *Conn)
+		reads     [][]byte
+		wantData  []byte
+		wantReply []byte
+		check     func(t *testing.T, c *Conn)
+	}{
+		{
+			name:     "plain data",
+			reads:    [][]byte{[]byte("hello")},
+			wantData: []byte("hello"),
+		},
+		{
+			name:     "escaped IAC is a data byte",
+			reads:    [][]byte{cat("a", IAC, IAC, "b")},
+			wantData: cat("a", IAC, "b"),
+		},
+		{
+			name:     "CR NUL is a bare CR, CR LF is kept",
+			reads:    [][]byte{cat("a\r", byte(0), "b\r\nc")},
+			wantData: []byte("a\rb\r\nc"),
+		},
+		{
+			name:     "command split across reads",
+			reads:    [][]byte{cat("a", IAC), cat(DO), cat(OptEcho, "b")},
+			wantData: []byte("ab"),
+			// Echo isn't supported, so it is refused
+			wantReply: cat(IAC, WONT, OptEcho),
+		},
+		{
+			name:     "interrupt process is Ctrl-C",
+			reads:    [][]byte{cat(IAC, IP)},
+			wantData: []byte{0x03},
+		},
+		{
+			name:     "NOP and GA are ignored",
+			reads:    [][]byte{cat("a", IAC, NOP, IAC, GA, "b")},
+			wantData: []byte("ab"),
+		},
+		{
+			name:      "unsupported option the client offers is refused",
+			reads:     [][]byte{cat(IAC, WILL, OptLinemode)},
+			wantReply: cat(IAC, DONT, OptLinemode),
+		},
+		{
+			name:      "supported option is agreed to",
+			reads:     [][]byte{cat(IAC, DO, OptSuppressGoAhead)},
+			wantReply: cat(IAC, WILL, OptSuppressGoAhead),
+			check: func(t *testing.T, c *Conn) {
+				if !c.LocalEnabled(OptSuppressGoAhead) {
+					t.Error("SGA not enabled")
+				}
+			},
+		},
+		{
+			name:  "answer to our own request isn't acknowledged again",
+			setup: func(c *Conn) { c.Will(OptSuppressGoAhead) },
+			reads: [][]byte{cat(IAC, DO, OptSuppressGoAhead)},
+			check: func(t *testing.T, c *Conn) {
+				if !c.LocalEnabled(OptSuppressGoAhead) || !c.settled() {
+					t.Error("SGA not enabled and settled")
+				}
+			},
+		},
+		{
+			name:  "repeated WILL for an enabled option isn't answered",
+			reads: [][]byte{cat(IAC, WILL, OptSuppressGoAhead, IAC, WILL, OptSuppressGoAhead)},
+			// Only the first one is acknowledged
+			wantReply: cat(IAC, DO, OptSuppressGoAhead),
+		},
+		{
+			name:  "DONT for a disabled option isn't answered",
+			reads: [][]byte{cat(IAC, DONT, OptEcho, IAC, WONT, OptEcho)},
+		},
+		{
+			name:      "WONT for an enabled option is acknowledged",
+			reads:     [][]byte{cat(IAC, WILL, OptSuppressGoAhead), cat(IAC, WONT, OptSuppressGoAhead)},
+			wantReply: cat(IAC, DO, OptSuppressGoAhead, IAC, DONT, OptSuppressGoAhead),
+		},
+		{
+			name:  "NAWS",
+			setup: func(c *Conn) { c.Do(OptNAWS) },
+			reads: [][]byte{cat(IAC, WILL, OptNAWS, IAC, SB, OptNAWS, byte(0), byte(80), byte(0), byte(24), IAC, SE, "x")},
+			check: func(t *testing.T, c *Conn) {
+				if w, h := c.WindowSize(); w != 80 || h != 24 {
+					t.Errorf("size %dx%d, want 80x24", w, h)
+				}
+				if !c.settled() {
+					t.Error("still awaiting NAWS")
+				}
+			},
+			wantData: []byte("x"),
+		},
+		{
+			name:  "NAWS with an escaped 255 split across reads",
+			setup: func(c *Conn) { c.Do(OptNAWS) },
+			reads: [][]byte{
+				cat(IAC, WILL, OptNAWS, IAC, SB, OptNAWS, byte(0), IAC),
+				cat(IAC, byte(1)),
+				cat(IAC),
+				cat(IAC, IAC, SE),
+			},
+			check: func(t *testing.T, c *Conn) {
+				if w, h := c.WindowSize(); w != 255 || h != 0x1ff {
+					t.Errorf("size %dx%d, want 255x511", w, h)
+				}
+			},
+		},
+		{
+			name:  "short NAWS is ignored",
+			setup: func(c *Conn) { c.Do(OptNAWS) },
+			reads: [][]byte{cat(IAC, WILL, OptNAWS, IAC, SB, OptNAWS, byte(0), byte(80), IAC, SE)},
+			check: func(t *testing.T, c *Conn) {
+				if w, h := c.WindowSize(); w != 0 || h != 0 {
+					t.Errorf("size %dx%d, want none", w, h)
+				}
+			},
+		},
+		{
+			name:  "malformed subnegotiation is dropped",
+			setup: func(c *Conn) { c.Do(OptNAWS) },
+			reads: [][]byte{cat(IAC, WILL, OptNAWS, IAC, SB, OptNAWS, byte(0), byte(80), IAC, NOP, "ok")},
+			check: func(t *testing.T, c *Conn) {
+				if w, _ := c.WindowSize(); w != 0 {
+					t.Errorf("width %d, want none", w)
+				}
+			},
+			wantData: []byte("ok"),
+		},
+		{
+			name:  "terminal types are collected until they repeat",
+			setup: func(c *Conn) { c.Do(OptTerminalType) },
+			reads: [][]byte{
+				cat(IAC, WILL, OptTerminalType),
+				cat(IAC, SB, OptTerminalType, ttypeIs, "XTERM", IAC, SE),
+				cat(IAC, SB, OptTerminalType, ttypeIs, "ANSI", IAC, SE),
+				cat(IAC, SB, OptTerminalType, ttypeIs, "ANSI", IAC, SE),
+			},
+			wantReply: cat(
+				IAC, SB, OptTerminalType, ttypeSend, IAC, SE,
+				IAC, SB, OptTerminalType, ttypeSend, IAC, SE,
+				IAC, SB, OptTerminalType, ttypeSend, IAC, SE,
+			),
+			check: func(t *testing.T, c *Conn) {
+				if got := c.TerminalTypes(); !reflect.DeepEqual(got, []string{"XTERM", "ANSI"}) {
+					t.Errorf("terminal types %q", got)
+				}
+				if !c.settled() {
+					t.Error("still awaiting TTYPE")
+				}
+			},
+		},
+		{
+			name:  "refused terminal type isn't waited for",
+			setup: func(c *Conn) { c.Do(OptTerminalType) },
+			reads: [][]byte{cat(IAC, WONT, OptTerminalType)},
+			check: func(t *testing.T, c *Conn) {
+				if !c.settled() || len(c.TerminalTypes()) != 0 {
+					t.Error("expected no terminal types and nothing outstanding")
+				}
+			},
+		},
+		{
+			name:  "charset offered and accepted",
+			setup: func(c *Conn) { c.OfferCharsets([]string{"UTF-8", "US-ASCII"}) },
+			reads: [][]byte{
+				cat(IAC, DO, OptCharset),
+				cat(IAC, SB, OptCharset, charsetAccepted, "UTF-8", IAC, SE),
+			},
+			wantReply: cat(IAC, SB, OptCharset, charsetRequest, ";UTF-8;US-ASCII", IAC, SE),
+			check: func(t *testing.T, c *Conn) {
+				if c.Charset() != "UTF-8" {
+					t.Errorf("charset %q", c.Charset())
+				}
+				if !c.settled() {
+					t.Error("still awaiting CHARSET")
+				}
+			},
+		},
+		{
+			name:      "client's own charset request is rejected",
+			setup:     func(c *Conn) { c.OfferCharsets([]string{"UTF-8"}) },
+			reads:     [][]byte{cat(IAC, WILL, OptCharset, IAC, SB, OptCharset, charsetRequest, ";KOI8-R", IAC, SE)},
+			wantReply: cat(IAC, DONT, OptCharset, IAC, SB, OptCharset, charsetRejected, IAC, SE),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeConn{reads: tt.reads}
+			c := NewConn(fake)
+			if tt.setup != nil {
+				tt.setup(c)
+			}
+			fake.written.Reset()
+
+			data, err := io.ReadAll(c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(data, tt.wantData) {
+				t.Errorf("data % x, want % x", data, tt.wantData)
+			}
+			if !bytes.Equal(fake.written.Bytes(), tt.wantReply) {
+				t.Errorf("reply % x, want % x", fake.written.Bytes(), tt.wantReply)
+			}
+			if tt.check != nil {
+				tt.check(t, c)
+			}
+		})
+	}
+}
+
+func TestOnWindowSize(t *testing.T) {
+	fake := &fakeConn{reads: [][]byte{
+		cat(IAC, SB, OptNAWS, byte(0), byte(40), byte(0), byte(25), IAC, SE),
+		cat(IAC, SB, OptNAWS, byte(0), byte(132), byte(0), byte(50), IAC, SE),
+	}}
+	c := NewConn(fake)
+	var sizes [][2]int
+	c.OnWindowSize = func(width, height int) {
+		sizes = append(sizes, [2]int{width, height})
+	}
+	io.ReadAll(c)
+
+	want := [][2]int{{40, 25}, {132, 50}}
+	if !reflect.DeepEqual(sizes, want) {
+		t.Errorf("sizes %v, want %v", sizes, want)
+	}
+}
+
+func TestNegotiateKeepsData(t *testing.T) {
+	fake := &fakeConn{reads: [][]byte{
+		cat(IAC, WILL, OptNAWS, "ab"),
+		cat(IAC, SB, OptNAWS, byte(0), byte(80), byte(0), byte(24), IAC, SE),
+	}}
+	c := NewConn(fake)
+	c.Do(OptNAWS)
+	if err := c.Negotiate(time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if w, h := c.WindowSize(); w != 80 || h != 24 {
+		t.Errorf("size %dx%d, want 80x24", w, h)
+	}
+
+	data, err := io.ReadAll(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "ab" {
+		t.Errorf("data %q, want \"ab\"", data)
+	}
+}
+
+func TestWriteEscapesIAC(t *testing.T) {
+	fake := &fakeConn{}
+	c := NewConn(fake)
+	n, err := c.Write(cat("a", IAC, "b"))
+	if err != nil || n != 3 {
+		t.Fatalf("wrote %d, %v", n, err)
+	}
+	if want := cat("a", IAC, IAC, "b"); !bytes.Equal(fake.written.Bytes(), want) {
+		t.Errorf("wrote % x, want % x", fake.written.Bytes(), want)
+	}
+}
@@ -0,0 +1,42 @@
+package terminal
+
+import "gemnet/internal/charset"
+
+// ATASCII drives an Atari 400/800/XL/XE, usually connected through
+// FujiNet. The screen editor has no cursor addressing, so the page is
+// always redrawn in full, and the selected link is shown with the
+// Atari's inverse video characters.
+var ATASCII = &Profile{
+	Name:        "atascii",
+	Description: "Atari 8-bit (ATASCII)",
+	ClearScreen: "\f", // The ATASCII charset sends this as the clear code
+	Charset:     charset.ATASCII,
+	Newline:     "\u009b", // EOL
+	Input:       atasciiInput(),
+	reverse:     charset.ATASCIIInverse,
+
+	Width:           40,
+	Height:          24,
+	ReturnIsNewline: true,
+}
+
+// atasciiInput maps Atari keys to their ASCII and ANSI equivalents
+func atasciiInput() map[byte]string {
+	input := map[byte]string{
+		0x1c: "\x1b[A",  // Cursor up
+		0x1d: "\x1b[B",  // Cursor down
+		0x1e: "\x1b[D",  // Cursor left
+		0x1f: "\x1b[C",  // Cursor right
+		0x9b: "\r",      // RETURN
+		0x7e: "\x7f",    // BACK S
+		0x7f: "\t",      // TAB
+		0x9c: "\x15",    // Shift-DELETE clears the line
+		0xfe: "\x1b[3~", // Ctrl-DELETE
+		0xff: "\x1b[2~", // Ctrl-INSERT
+	}
+	// The inverse video key sets the high bit of everything typed
+	for c := byte(' '); c < 0x7d; c++ {
+		input[c|0x80] = string(c)
+	}
+	return input
+}
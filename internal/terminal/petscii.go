@@ -0,0 +1,56 @@
+package terminal
+
+import (
+	"strings"
+
+	"gemnet/internal/charset"
+)
+
+// PETSCII drives a Commodore 64 or 128 in lower/upper case mode. There
+// is no absolute cursor positioning, so the cursor is homed and stepped
+// down and across. Control codes above 0x7F are written as C1 control
+// characters, which the PETSCII charset encodes back to single bytes.
+var PETSCII = &Profile{
+	Name:        "petscii",
+	Description: "Commodore 64/128 (PETSCII)",
+	ClearScreen: "\u0093\u000e\u0005", // Clear, lower case mode, white
+	Reverse:     "\u0012",             // RVS on
+	Bold:        "\u009e",             // Yellow
+	Reset:       "\u0092\u0005",       // RVS off, white
+	Charset:     charset.PETSCII,
+	Newline:     "\r",
+	Input:       petsciiInput(),
+
+	Width:           40,
+	Height:          25,
+	ReturnIsNewline: true,
+
+	moveTo: func(row, col int) string {
+		return "\u0013" + strings.Repeat("\u0011", row-1) + strings.Repeat("\u001d", col-1)
+	},
+}
+
+// petsciiInput maps C64 keys to their ASCII and ANSI equivalents
+func petsciiInput() map[byte]string {
+	input := map[byte]string{
+		0x91: "\x1b[A",  // Cursor up
+		0x11: "\x1b[B",  // Cursor down
+		0x1d: "\x1b[C",  // Cursor right
+		0x9d: "\x1b[D",  // Cursor left
+		0x13: "\x1b[H",  // HOME
+		0x94: "\x1b[2~", // INST
+		0x14: "\x7f",    // DEL
+		0x85: "\x1b[5~", // F1: page up
+		0x88: "\x1b[6~", // F7: page down
+		0x8d: "\r",      // Shifted RETURN
+		0x5f: "\x1b",    // The ← key stands in for ESC
+		0xa0: " ",       // Shifted space
+	}
+	// Letters arrive with their case swapped
+	for c := byte('a'); c <= 'z'; c++ {
+		input[c-'a'+0x41] = string(c)
+		input[c-'a'+0xc1] = string(c - 'a' + 'A')
+		input[c-'a'+0x61] = string(c - 'a' + 'A')
+	}
+	return input
+}
@@ -0,0 +1,186 @@
+package terminal
+
+import (
+	"fmt"
+	"strings"
+
+	"gemnet/internal/charset"
+)
+
+// Profile describes how to drive a particular kind of terminal
+type Profile struct {
+	Name        string
+	Description string
+
+	ClearScreen string // Clear the screen and home the cursor
+	EraseLine   string // Erase from cursor to end of line ("" if unsupported)
+	Reverse     string // Start reverse video ("" if unsupported)
+	Bold        string // Start bold ("" if unsupported)
+	Reset       string // Turn off all attributes
+
+	Charset *charset.Charset // Character set the terminal always uses (nil to negotiate one)
+	Newline string           // Line ending the terminal needs ("" for the session's)
+
+	// Width and Height are the screen size of terminals that only come in
+	// one size, assumed when the client doesn't report its size (0 for
+	// the configured default)
+	Width, Height int
+
+	// ReturnIsNewline is set for terminals where CR also moves down a
+	// line, so the cursor has to back up to rewrite the line it is on
+	ReturnIsNewline bool
+
+	// Input translates bytes the terminal sends for its own keys into
+	// the ASCII or ANSI sequences the session understands
+	Input map[byte]string
+
+	// Teletype prints each page as a continuous listing, pausing every
+	// screenful, with links chosen by number rather than highlighted
+	Teletype bool
+
+	// reverse returns a line in reverse video, for terminals that mark
+	// each character rather than switching modes with Reverse
+	reverse func(line string) string
+
+	// moveTo returns the sequence that moves the cursor to a 1-indexed
+	// row and column, or nil if the terminal has no cursor addressing
+	moveTo func(row, col int) string
+}
+
+// CanAddress reports whether the terminal supports absolute cursor positioning
+func (p *Profile) CanAddress() bool {
+	return p.moveTo != nil
+}
+
+// MoveTo returns the sequence to move the cursor to a 1-indexed row and column
+func (p *Profile) MoveTo(row, col int) string {
+	if p.moveTo == nil {
+		return ""
+	}
+	return p.moveTo(row, col)
+}
+
+// HasReverse reports whether the terminal can show reverse video
+func (p *Profile) HasReverse() bool {
+	return p.Reverse != "" || p.reverse != nil
+}
+
+// ReverseVideo returns line in reverse video
+func (p *Profile) ReverseVideo(line string) string {
+	if p.reverse != nil {
+		return p.reverse(line)
+	}
+	return p.Reverse + line + p.Reset
+}
+
+var ANSI = &Profile{
+	Name:        "ansi",
+	Description: "VT100/ANSI",
+	ClearScreen: "\x1b[2J\x1b[H",
+	EraseLine:   "\x1b[K",
+	Reverse:     "\x1b[7m",
+	Bold:        "\x1b[1m",
+	Reset:       "\x1b[0m",
+	moveTo: func(row, col int) string {
+		return fmt.Sprintf("\x1b[%d;%dH", row, col)
+	},
+}
+
+var VT52 = &Profile{
+	Name:        "vt52",
+	Description: "DEC VT52",
+	ClearScreen: "\x1bH\x1bJ",
+	EraseLine:   "\x1bK",
+	moveTo:      vt52MoveTo,
+}
+
+// H19 is the Heathkit/Zenith H19, a VT52 superset with reverse video
+var H19 = &Profile{
+	Name:        "h19",
+	Description: "Heath/Zenith H19",
+	ClearScreen: "\x1bE",
+	EraseLine:   "\x1bK",
+	Reverse:     "\x1bp",
+	Reset:       "\x1bq",
+	moveTo:      vt52MoveTo,
+}
+
+// Dumb is for glass and hardcopy teletypes with no control sequences at all
+var Dumb = &Profile{
+	Name:        "dumb",
+	Description: "Dumb terminal or teletype (line by line)",
+	ClearScreen: "\r\n",
+	Teletype:    true,
+}
+
+// Profiles lists every available profile in menu order
+var Profiles = []*Profile{ANSI, VT52, H19, PETSCII, ATASCII, Dumb}
+
+func vt52MoveTo(row, col int) string {
+	return fmt.Sprintf("\x1bY%c%c", byte(31+row), byte(31+col))
+}
+
+// Lookup returns the profile with the given name, or nil
+func Lookup(name string) *Profile {
+	for _, p := range Profiles {
+		if strings.EqualFold(p.Name, name) {
+			return p
+		}
+	}
+	return nil
+}
+
+// terminalTypes maps TERMINAL-TYPE name prefixes to profiles. More
+// specific prefixes must come before shorter ones.
+var terminalTypes = []struct {
+	prefix  string
+	profile *Profile
+}{
+	{"vt52", VT52},
+	{"dec-vt52", VT52},
+	{"h19", H19},
+	{"h-19", H19},
+	{"heath", H19},
+	{"zenith", H19},
+	{"z19", H19},
+	{"petscii", PETSCII},
+	{"commodore", PETSCII},
+	{"c64", PETSCII},
+	{"c128", PETSCII},
+	{"atascii", ATASCII},
+	{"atari", ATASCII},
+	{"fujinet", ATASCII},
+	{"dumb", Dumb},
+	{"unknown", Dumb},
+	{"tty", Dumb},
+	{"teletype", Dumb},
+	{"glasstty", Dumb},
+	{"hardcopy", Dumb},
+	{"vt", ANSI},
+	{"dec-vt", ANSI},
+	{"ansi", ANSI},
+	{"xterm", ANSI},
+	{"linux", ANSI},
+	{"screen", ANSI},
+	{"tmux", ANSI},
+	{"rxvt", ANSI},
+	{"putty", ANSI},
+	{"cygwin", ANSI},
+	{"syncterm", ANSI},
+	{"pcansi", ANSI},
+	{"scoansi", ANSI},
+	{"konsole", ANSI},
+	{"netterm", ANSI},
+}
+
+// ForTerminalType picks a profile for a TERMINAL-TYPE name reported by
+// the client (RFC 1091). It returns nil for names it doesn't recognise.
+func ForTerminalType(name string) *Profile {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, t := range terminalTypes {
+		if strings.HasPrefix(name, t.prefix) {
+			return t.profile
+		}
+	}
+	return nil
+}